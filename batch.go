@@ -0,0 +1,136 @@
+package structmapper
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// This file contains the batch functionality of Mapper
+
+// forEach calls fn for each index from 0 to n-1, using the configured number of workers.
+// fn reports whether processing the index failed. In fail-fast mode, indexes following a failed one
+// are skipped, while all indexes preceding it are still processed. This way the first failing index is
+// the same as if all indexes had been processed sequentially.
+func (sm *Mapper) forEach(n int, fn func(i int) bool) {
+	if sm.workers <= 1 || n < 2 {
+		for i := 0; i < n; i++ {
+			if !fn(i) && sm.failFast {
+				return
+			}
+		}
+		return
+	}
+
+	var next, firstFailed atomic.Int64
+	next.Store(-1)
+	firstFailed.Store(int64(n))
+
+	var wg sync.WaitGroup
+	for w := 0; w < sm.workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := next.Add(1)
+				if i >= int64(n) || (sm.failFast && i > firstFailed.Load()) {
+					return
+				}
+
+				if fn(int(i)) {
+					continue
+				}
+
+				// Record the lowest failed index
+				for {
+					failed := firstFailed.Load()
+					if i >= failed || firstFailed.CompareAndSwap(failed, i) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// collectErrors aggregates the non-nil errors in index order, with their paths prefixed by the index.
+// In fail-fast mode, only the first error is returned.
+func (sm *Mapper) collectErrors(errs []error) (err error) {
+	for i, elemErr := range errs {
+		if elemErr == nil {
+			continue
+		}
+
+		err = appendErrors(err, prefixErrorPaths(elemErr, indexPath(i)))
+		if sm.failFast {
+			return
+		}
+	}
+	return
+}
+
+func (sm *Mapper) toMaps(s interface{}) ([]map[string]interface{}, error) {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, ErrNotASlice
+	}
+
+	results := make([]map[string]interface{}, v.Len())
+	errs := make([]error, v.Len())
+	sm.forEach(v.Len(), func(i int) bool {
+		results[i], errs[i] = sm.toMap(v.Index(i).Interface())
+		return errs[i] == nil
+	})
+
+	if err := sm.collectErrors(errs); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (sm *Mapper) toStructs(sources []map[string]interface{}, s interface{}) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return ErrNotASlicePointer
+	}
+
+	sliceT := v.Elem().Type()
+	elemT := sliceT.Elem()
+	isPtr := elemT.Kind() == reflect.Ptr
+	if isPtr {
+		elemT = elemT.Elem()
+	}
+	if elemT.Kind() != reflect.Struct {
+		return ErrNotAStruct
+	}
+
+	// Map onto a new slice, which is only applied if no error occurred
+	results := reflect.MakeSlice(sliceT, len(sources), len(sources))
+	errs := make([]error, len(sources))
+	sm.forEach(len(sources), func(i int) bool {
+		target := reflect.New(elemT)
+		if errs[i] = sm.toStruct(sources[i], target.Interface()); errs[i] != nil {
+			return false
+		}
+
+		if isPtr {
+			results.Index(i).Set(target)
+		} else {
+			results.Index(i).Set(target.Elem())
+		}
+		return true
+	})
+
+	if err := sm.collectErrors(errs); err != nil {
+		return err
+	}
+
+	v.Elem().Set(results)
+	return nil
+}
+
+// indexPath returns the path of the slice element at index i
+func indexPath(i int) string {
+	return formatPath([]pathElement{{index: i}})
+}
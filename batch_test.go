@@ -0,0 +1,168 @@
+package structmapper_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionWorkers(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionWorkers(-1))
+	require.Error(t, err)
+	require.Nil(t, sm)
+}
+
+func TestMapper_ToStructs(t *testing.T) {
+	sequential, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sequential)
+
+	concurrent, err := structmapper.NewMapper(structmapper.OptionWorkers(8))
+	require.NoError(t, err)
+	require.NotNil(t, concurrent)
+
+	t.Run("OK", func(t *testing.T) {
+		sources := make([]map[string]interface{}, 99)
+		for i := range sources {
+			sources[i] = map[string]interface{}{
+				"a": fmt.Sprintf("test%d", i),
+				"b": i,
+			}
+		}
+
+		var expected []mapperTestStructNested
+		require.NoError(t, sequential.ToStructs(sources, &expected))
+		require.Len(t, expected, 99)
+		require.EqualValues(t, mapperTestStructNested{A: "test42", B: 42}, expected[42])
+
+		var values []mapperTestStructNested
+		require.NoError(t, concurrent.ToStructs(sources, &values))
+		require.EqualValues(t, expected, values)
+
+		var pointers []*mapperTestStructNested
+		require.NoError(t, concurrent.ToStructs(sources, &pointers))
+		require.Len(t, pointers, 99)
+		for i := range expected {
+			require.EqualValues(t, &expected[i], pointers[i])
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// Every 100th source fails, starting at index 99
+		sources := make([]map[string]interface{}, 1000)
+		for i := range sources {
+			sources[i] = map[string]interface{}{
+				"a": fmt.Sprintf("test%d", i),
+				"b": i,
+			}
+			if i%100 == 99 {
+				sources[i]["c"] = "invalid"
+			}
+		}
+
+		existing := []mapperTestStructNested{{A: "existing"}}
+		values := existing
+		err := sequential.ToStructs(sources, &values)
+		fieldErrors := requireFieldErrors(t, err, "[99].c", "[199].c", "[299].c", "[399].c", "[499].c", "[599].c",
+			"[699].c", "[799].c", "[899].c", "[999].c")
+		require.EqualValues(t, "C", fieldErrors["[99].c"].Field())
+		require.EqualValues(t, existing, values)
+
+		concurrentErr := concurrent.ToStructs(sources, &values)
+		require.EqualValues(t, err, concurrentErr)
+		require.EqualValues(t, existing, values)
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		// Every 100th source fails, starting at index 99
+		sources := make([]map[string]interface{}, 1000)
+		for i := range sources {
+			sources[i] = map[string]interface{}{
+				"a": fmt.Sprintf("test%d", i),
+				"b": i,
+			}
+			if i%100 == 99 {
+				sources[i]["c"] = "invalid"
+			}
+		}
+
+		for _, workers := range []int{0, 8} {
+			sm, err := structmapper.NewMapper(structmapper.OptionFailFast(true), structmapper.OptionWorkers(workers))
+			require.NoError(t, err)
+			require.NotNil(t, sm)
+
+			var values []mapperTestStructNested
+			requireFieldErrors(t, sm.ToStructs(sources, &values), "[99].c")
+			require.Nil(t, values)
+		}
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		var values []mapperTestStructNested
+		require.EqualError(t, sequential.ToStructs(nil, values), structmapper.ErrNotASlicePointer.Error())
+
+		var strings []string
+		require.EqualError(t, sequential.ToStructs(nil, &strings), structmapper.ErrNotAStruct.Error())
+	})
+}
+
+func TestMapper_ToMaps(t *testing.T) {
+	sequential, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+	require.NoError(t, err)
+	require.NotNil(t, sequential)
+
+	concurrent, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError),
+		structmapper.OptionWorkers(8))
+	require.NoError(t, err)
+	require.NotNil(t, concurrent)
+
+	t.Run("OK", func(t *testing.T) {
+		sources := make([]mapperTestStructNested, 99)
+		for i := range sources {
+			sources[i] = mapperTestStructNested{A: fmt.Sprintf("test%d", i), B: i}
+		}
+
+		expected, err := sequential.ToMaps(sources)
+		require.NoError(t, err)
+		require.Len(t, expected, 99)
+
+		maps, err := concurrent.ToMaps(sources)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, maps)
+
+		// Arrays of pointers are supported as well
+		maps, err = concurrent.ToMaps([2]*mapperTestStructNested{&sources[0], &sources[1]})
+		require.NoError(t, err)
+		require.EqualValues(t, expected[:2], maps)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		sources := make([]interface{}, 300)
+		for i := range sources {
+			if i%100 == 42 {
+				sources[i] = &mapperTestStructOpaque{S: []interface{}{"s0"}}
+			} else {
+				sources[i] = &mapperTestStructSimple{A: fmt.Sprint(i)}
+			}
+		}
+
+		maps, err := sequential.ToMaps(sources)
+		require.Nil(t, maps)
+		fieldErrors := requireFieldErrors(t, err, "[42].c", "[42].f", "[42].p", "[142].c", "[142].f", "[142].p",
+			"[242].c", "[242].f", "[242].p")
+		require.True(t, errors.Is(fieldErrors["[42].c"], structmapper.ErrUnsupportedKind))
+
+		maps, concurrentErr := concurrent.ToMaps(sources)
+		require.Nil(t, maps)
+		require.EqualValues(t, err, concurrentErr)
+	})
+
+	t.Run("NotASlice", func(t *testing.T) {
+		maps, err := sequential.ToMaps(&mapperTestStructSimple{})
+		require.Nil(t, maps)
+		require.EqualError(t, err, structmapper.ErrNotASlice.Error())
+	})
+}
@@ -0,0 +1,225 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// This file contains the deep clone functionality of Mapper
+
+// cloneValue deep-copies src onto dst, which has to be a settable zero value of the same type.
+// Pointers which have been cloned before are not cloned again, but the existing clone is reused, so
+// shared pointers stay shared and cycles are preserved.
+// Channel, function and unsafe.Pointer values are copied as-is, regardless of the KindPolicy.
+func (sm *Mapper) cloneValue(st *state, src reflect.Value, dst reflect.Value) error {
+	if err := sm.checkDepth(st); err != nil {
+		return err
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return nil
+		}
+
+		key := ptrKey{ptr: src.Pointer(), typ: src.Type()}
+		if clone, ok := st.clones[key]; ok {
+			// Pointer has been cloned before: share the existing clone
+			dst.Set(clone)
+			return nil
+		}
+
+		clone := reflect.New(src.Type().Elem())
+		st.clones[key] = clone
+		dst.Set(clone)
+		return sm.cloneValue(st, src.Elem(), clone.Elem())
+	case reflect.Interface:
+		if src.IsNil() {
+			return nil
+		}
+
+		elem := src.Elem()
+		clone := reflect.New(elem.Type()).Elem()
+		if err := sm.cloneValue(st, elem, clone); err != nil {
+			return err
+		}
+		dst.Set(clone)
+		return nil
+	case reflect.Struct:
+		return sm.cloneStruct(st, src, dst)
+	case reflect.Slice:
+		if src.IsNil() {
+			// Keep nil slices nil
+			return nil
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		return sm.cloneElements(st, src, dst)
+	case reflect.Array:
+		return sm.cloneElements(st, src, dst)
+	case reflect.Map:
+		return sm.cloneMap(st, src, dst)
+	}
+
+	// All other types are copied as-is
+	dst.Set(src)
+	return nil
+}
+
+// cloneStruct deep-copies the struct src onto dst.
+// Fields tagged with "-" are copied as-is. Unexported fields are copied as-is as well, unless cloning them
+// has been enabled using OptionCloneUnexported.
+func (sm *Mapper) cloneStruct(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	t := src.Type()
+	if err = sm.countElements(st, t.NumField()); err != nil {
+		return
+	}
+
+	if !src.CanAddr() {
+		// Unexported fields can only be accessed if src is addressable
+		addressable := reflect.New(t).Elem()
+		addressable.Set(src)
+		src = addressable
+	}
+
+	// Start with a shallow copy, so unexported fields are retained
+	dst.Set(src)
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldD := t.Field(i)
+		srcField, dstField := src.Field(i), dst.Field(i)
+		if fieldD.Tag.Get(sm.tagName) == "-" {
+			// Field is ignored by the Mapper, keep the shallow copy
+			continue
+		} else if !fieldD.IsExported() {
+			if !sm.cloneUnexported {
+				continue
+			}
+			srcField = reflect.NewAt(fieldD.Type, unsafe.Pointer(srcField.UnsafeAddr())).Elem()
+			dstField = reflect.NewAt(fieldD.Type, unsafe.Pointer(dstField.UnsafeAddr())).Elem()
+		}
+
+		// Clone onto a new value, so the shallow copy is replaced
+		clone := reflect.New(fieldD.Type).Elem()
+		st.push(fieldD.Name)
+		leave := st.enterField(fieldD.Name)
+		cloneErr := sm.cloneValue(st, srcField, clone)
+		if cloneErr != nil {
+			cloneErr = st.fieldError(cloneErr, fieldD.Type, srcField.Interface())
+		}
+		leave()
+		st.pop()
+		if cloneErr != nil {
+			err = appendErrors(err, cloneErr)
+			if st.done() {
+				return
+			}
+			continue
+		}
+		dstField.Set(clone)
+	}
+
+	return
+}
+
+// cloneElements deep-copies the elements of the slice or array src onto the elements of dst, which has
+// the same length
+func (sm *Mapper) cloneElements(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	if err = sm.checkLength(st, src.Len()); err != nil {
+		return
+	}
+
+	for i := 0; i < src.Len(); i++ {
+		srcElem := src.Index(i)
+
+		st.pushIndex(i)
+		cloneErr := sm.cloneValue(st, srcElem, dst.Index(i))
+		if cloneErr != nil {
+			cloneErr = st.fieldError(cloneErr, srcElem.Type(), srcElem.Interface())
+		}
+		st.pop()
+		if cloneErr != nil {
+			err = appendErrors(err, cloneErr)
+			if st.done() {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// cloneMap deep-copies the entries of the map src onto a new map, which dst is set to
+func (sm *Mapper) cloneMap(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	if src.IsNil() {
+		// Keep nil maps nil
+		return
+	}
+
+	if err = sm.checkLength(st, src.Len()); err != nil {
+		return
+	}
+
+	t := src.Type()
+	out := reflect.MakeMapWithSize(t, src.Len())
+
+	// Process the entries sorted by key, so errors are reported in a deterministic order
+	for _, srcKey := range sortedMapKeys(src) {
+		srcValue := src.MapIndex(srcKey)
+
+		st.push(fmt.Sprint(srcKey.Interface()))
+		key := reflect.New(t.Key()).Elem()
+		cloneErr := sm.cloneValue(st, srcKey, key)
+		if cloneErr != nil {
+			cloneErr = st.fieldError(cloneErr, t.Key(), srcKey.Interface())
+		}
+
+		value := reflect.New(t.Elem()).Elem()
+		if cloneErr == nil {
+			if cloneErr = sm.cloneValue(st, srcValue, value); cloneErr != nil {
+				cloneErr = st.fieldError(cloneErr, t.Elem(), srcValue.Interface())
+			}
+		}
+		st.pop()
+
+		if cloneErr != nil {
+			err = appendErrors(err, cloneErr)
+			if st.done() {
+				return
+			}
+			continue
+		}
+		out.SetMapIndex(key, value)
+	}
+
+	dst.Set(out)
+	return
+}
+
+func (sm *Mapper) clone(s interface{}) (interface{}, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(s)
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, ErrNotAStruct
+	}
+
+	st := sm.newState()
+	st.clones = make(map[ptrKey]reflect.Value)
+
+	clone := reflect.New(v.Type()).Elem()
+	if err := sm.cloneValue(st, v, clone); err != nil {
+		if st.aborted() {
+			// Return the error which aborted the call as-is
+			return nil, st.abortErr
+		}
+		return nil, err
+	}
+	return clone.Interface(), nil
+}
@@ -0,0 +1,188 @@
+package structmapper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructClone struct {
+	Name     string                    `mapper:"name"`
+	Created  time.Time                 `mapper:"created"`
+	Server   *mapperTestStructServer   `mapper:"server"`
+	Shared   *mapperTestStructServer   `mapper:"shared"`
+	List     []*mapperTestStructServer `mapper:"list"`
+	Nil      []string                  `mapper:"nil"`
+	Empty    []string                  `mapper:"empty"`
+	Map      map[string][]int          `mapper:"map"`
+	NilMap   map[string]int            `mapper:"nil_map"`
+	Any      interface{}               `mapper:"any"`
+	Array    [2]*int                   `mapper:"array"`
+	Callback func()                    `mapper:"callback"`
+	Events   chan int                  `mapper:"events"`
+	Ignored  *mapperTestStructServer   `mapper:"-"`
+	private  *mapperTestStructServer
+}
+
+func TestMapper_Clone(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("OK", func(t *testing.T) {
+		shared := &mapperTestStructServer{Name: "shared", Port: 80}
+		one := 1
+		source := &mapperTestStructClone{
+			Name:    "name",
+			Created: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+			Server:  &mapperTestStructServer{Name: "web", Port: 443, TLS: &mapperTestStructTLS{Cert: "cert"}},
+			Shared:  shared,
+			List:    []*mapperTestStructServer{shared, nil},
+			Empty:   []string{},
+			Map: map[string][]int{
+				"a": {1, 2},
+			},
+			Any:      map[string]interface{}{"b": []interface{}{"c"}},
+			Array:    [2]*int{&one, &one},
+			Callback: func() {},
+			Events:   make(chan int),
+			Ignored:  &mapperTestStructServer{Name: "ignored"},
+			private:  &mapperTestStructServer{Name: "private"},
+		}
+
+		result, err := sm.Clone(source)
+		require.NoError(t, err)
+		clone, ok := result.(*mapperTestStructClone)
+		require.True(t, ok, "clone is not a *mapperTestStructClone")
+		require.False(t, clone == source)
+
+		require.EqualValues(t, source.Name, clone.Name)
+		require.True(t, source.Created.Equal(clone.Created))
+		require.EqualValues(t, source.Server, clone.Server)
+		require.False(t, source.Server == clone.Server)
+		require.False(t, source.Server.TLS == clone.Server.TLS)
+		require.EqualValues(t, source.Map, clone.Map)
+		require.EqualValues(t, source.Any, clone.Any)
+
+		// nil and empty values are retained
+		require.Nil(t, clone.Nil)
+		require.NotNil(t, clone.Empty)
+		require.Len(t, clone.Empty, 0)
+		require.Nil(t, clone.NilMap)
+		require.Nil(t, clone.List[1])
+
+		// Shared pointers stay shared
+		require.False(t, source.Shared == clone.Shared)
+		require.True(t, clone.Shared == clone.List[0], "pointers are not shared")
+		require.True(t, clone.Array[0] == clone.Array[1], "pointers are not shared")
+		require.EqualValues(t, 1, *clone.Array[0])
+
+		// Functions and channels are copied as-is
+		require.NotNil(t, clone.Callback)
+		require.True(t, source.Events == clone.Events)
+
+		// Fields tagged with "-" are copied as-is
+		require.True(t, source.Ignored == clone.Ignored)
+
+		// Unexported fields are copied as-is by default
+		require.True(t, source.private == clone.private)
+
+		// Modifying the clone does not affect the source
+		clone.Map["a"][0] = 3
+		clone.Any.(map[string]interface{})["b"].([]interface{})[0] = "d"
+		clone.Shared.Name = "modified"
+		*clone.Array[0] = 2
+		require.EqualValues(t, 1, source.Map["a"][0])
+		require.EqualValues(t, "c", source.Any.(map[string]interface{})["b"].([]interface{})[0])
+		require.EqualValues(t, "shared", source.Shared.Name)
+		require.EqualValues(t, 1, *source.Array[0])
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		source := mapperTestStructSimple{A: "value"}
+		clone, err := sm.Clone(source)
+		require.NoError(t, err)
+		require.EqualValues(t, source, clone)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		result, err := sm.Clone(newMapperTestTree())
+		require.NoError(t, err)
+		root := result.(*mapperTestStructNode)
+
+		require.EqualValues(t, "root", root.Name)
+		require.Len(t, root.Children, 1)
+		child := root.Children[0]
+		require.EqualValues(t, "child", child.Name)
+		require.True(t, root == child.Parent, "child does not point back to root")
+		require.Len(t, child.Children, 1)
+		require.True(t, child == child.Children[0].Parent, "grandchild does not point back to child")
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		clone, err := sm.Clone((*mapperTestStructSimple)(nil))
+		require.NoError(t, err)
+		require.Nil(t, clone)
+		require.IsType(t, (*mapperTestStructSimple)(nil), clone)
+
+		clone, err = sm.Clone(nil)
+		require.NoError(t, err)
+		require.Nil(t, clone)
+	})
+
+	t.Run("NotAStruct", func(t *testing.T) {
+		clone, err := sm.Clone("test")
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+		require.Nil(t, clone)
+	})
+
+	t.Run("KindPolicy", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+		require.NoError(t, err)
+
+		source := &mapperTestStructClone{
+			Callback: func() {},
+			Events:   make(chan int),
+		}
+		result, err := sm.Clone(source)
+		require.NoError(t, err)
+		clone := result.(*mapperTestStructClone)
+		require.NotNil(t, clone.Callback)
+		require.True(t, source.Events == clone.Events)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMaxDepth(1))
+		require.NoError(t, err)
+
+		_, err = sm.Clone(&mapperTestStructNode{
+			Name: "root",
+			Children: []*mapperTestStructNode{
+				{Name: "child"},
+			},
+		})
+		limitErr, ok := structmapper.IsLimitError(err)
+		require.True(t, ok, "returned error is not a *LimitError")
+		require.EqualValues(t, structmapper.LimitDepth, limitErr.Limit())
+	})
+}
+
+func TestOptionCloneUnexported(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionCloneUnexported(true))
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	source := &mapperTestStructClone{
+		Created: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		private: &mapperTestStructServer{Name: "private"},
+	}
+	result, err := sm.Clone(source)
+	require.NoError(t, err)
+	clone := result.(*mapperTestStructClone)
+
+	require.False(t, source.private == clone.private)
+	require.EqualValues(t, source.private, clone.private)
+	require.True(t, source.Created.Equal(clone.Created))
+}
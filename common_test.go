@@ -2,6 +2,7 @@ package structmapper_test
 
 import (
 	"testing"
+	"time"
 
 	"net"
 
@@ -57,6 +58,67 @@ type MapperTestStructAnonymousPtrOuter struct {
 	A string `mapper:"a_outer"`
 }
 
+type MapperTestStructPromotionInnerA struct {
+	A string `mapper:"a"`
+	B string `mapper:"b"`
+	C string
+	D string `mapper:"d"`
+}
+
+type MapperTestStructPromotionInnerB struct {
+	B string `mapper:"b"`
+	C string `mapper:"C"`
+	D string `mapper:"d"`
+}
+
+type MapperTestStructPromotionOuter struct {
+	MapperTestStructPromotionInnerA
+	*MapperTestStructPromotionInnerB
+	A string `mapper:"a"`
+}
+
+type mapperTestStructAnonymousPrivateInner struct {
+	B string `mapper:"b_inner"`
+}
+
+type MapperTestStructAnonymousPrivatePtrOuter struct {
+	A string `mapper:"a_outer"`
+	*mapperTestStructAnonymousPrivateInner
+}
+
+type MapperTestStructTaggedAnonymousOuter struct {
+	MapperTestStructAnonymousInner   `mapper:"meta"`
+	*MapperTestStructPromotionInnerB `mapper:"-"`
+	A                                string `mapper:"a_outer"`
+}
+
+type MapperTestTags []string
+
+type MapperTestStringer interface {
+	String() string
+}
+
+type mapperTestStringerValue string
+
+func (v mapperTestStringerValue) String() string {
+	return string(v)
+}
+
+type MapperTestStructEmbeddedNonStruct struct {
+	MapperTestTags
+	MapperTestStringer
+	A string `mapper:"a"`
+}
+
+type MapperTestStructEmbeddedTime struct {
+	time.Time
+	A string `mapper:"a"`
+}
+
+type mapperTestStructEmbeddedTimeHolder struct {
+	W MapperTestStructEmbeddedTime `mapper:"w"`
+}
+
 type mapperTestStructMapStringString struct {
 	A string
 	B map[string]string
@@ -84,6 +146,17 @@ type mapperTestStructMapInt16 struct {
 	Data map[string]int16 `mapper:"data"`
 }
 
+type mapperTestStructTLS struct {
+	Cert string `mapper:"cert"`
+}
+
+type mapperTestStructServer struct {
+	Name  string               `mapper:"name"`
+	Port  int                  `mapper:"port"`
+	Proxy string               `mapper:"proxy,omitempty"`
+	TLS   *mapperTestStructTLS `mapper:"tls,omitempty"`
+}
+
 func TestMapper_Roundtrip(t *testing.T) {
 	// Test round-trips between map/unmap
 
@@ -436,3 +509,54 @@ func TestMapper_Roundtrip(t *testing.T) {
 		require.NoError(t, sm.ToStruct(m, target))
 	})
 }
+
+type MapperTestStructAtomicInner struct {
+	A  string  `mapper:"a"`
+	IP *net.IP `mapper:"ip"`
+}
+
+type MapperTestStructAtomicMiddle struct {
+	*MapperTestStructAtomicInner
+	B     int                          `mapper:"b"`
+	Inner *MapperTestStructAtomicInner `mapper:"inner"`
+}
+
+type MapperTestStructAtomicOuter struct {
+	*MapperTestStructAtomicInner
+	Middle MapperTestStructAtomicMiddle   `mapper:"middle"`
+	Items  []MapperTestStructAtomicMiddle `mapper:"items"`
+	C      float64                        `mapper:"c"`
+}
+
+// newMapperTestStructAtomicOuter returns a new target, so targets of failed calls can be compared to an unmodified one
+func newMapperTestStructAtomicOuter() *MapperTestStructAtomicOuter {
+	inner := func(a string) *MapperTestStructAtomicInner {
+		ip := net.IPv4(192, 168, 0, 1)
+		return &MapperTestStructAtomicInner{
+			A:  a,
+			IP: &ip,
+		}
+	}
+
+	return &MapperTestStructAtomicOuter{
+		MapperTestStructAtomicInner: inner("root"),
+		Middle: MapperTestStructAtomicMiddle{
+			MapperTestStructAtomicInner: inner("embedded"),
+			B:                           1,
+			Inner:                       inner("inner"),
+		},
+		Items: []MapperTestStructAtomicMiddle{
+			{
+				MapperTestStructAtomicInner: inner("embedded"),
+				B:                           2,
+				Inner:                       inner("inner"),
+			},
+			{
+				MapperTestStructAtomicInner: inner("embedded"),
+				B:                           3,
+				Inner:                       inner("inner"),
+			},
+		},
+		C: 1.5,
+	}
+}
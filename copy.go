@@ -0,0 +1,315 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// This file contains the struct to struct copy functionality of Mapper
+
+// copyValue copies src onto dst, which has to be settable.
+// Structs, slices, arrays and maps are copied recursively if dst is of a matching kind. All other values
+// are converted like ToStruct converts the values returned by ToMap.
+// errSkipValue is returned if the value is skipped due to the KindPolicy.
+func (sm *Mapper) copyValue(st *state, src reflect.Value, dst reflect.Value) error {
+	if err := sm.checkDepth(st); err != nil {
+		return err
+	}
+
+	t := dst.Type()
+	if err := checkOpaqueKind(sm.kindPolicy, t); err != nil {
+		return err
+	}
+
+	for src.Kind() == reflect.Interface && !src.IsNil() {
+		src = src.Elem()
+	}
+	if !src.IsValid() || ((src.Kind() == reflect.Ptr || src.Kind() == reflect.Interface) && src.IsNil()) {
+		// nil resets the value, like it does in ToStruct
+		dst.Set(reflect.Zero(t))
+		return nil
+	}
+
+	if src.Type().Implements(textMarshalerType) || isOpaqueKind(src.Kind()) {
+		return sm.copyLeaf(st, src, dst)
+	}
+
+	if src.Kind() == reflect.Ptr {
+		if err := checkOpaqueKind(sm.kindPolicy, src.Type().Elem()); err != nil {
+			return err
+		}
+
+		key := ptrKey{ptr: src.Pointer(), typ: src.Type()}
+		if depth, ok := st.pointers.visiting[key]; ok {
+			// Pointer is already being copied further up the path
+			return newErrorCycle(st.pathString(), formatPath(st.path[:depth]))
+		}
+		st.pointers.visiting[key] = len(st.path)
+		defer delete(st.pointers.visiting, key)
+
+		src = src.Elem()
+	}
+
+	// Copy onto a new value if dst is a pointer
+	target := dst
+	if t.Kind() == reflect.Ptr {
+		target = reflect.New(t.Elem()).Elem()
+	}
+
+	var err error
+	switch {
+	case src.Kind() == reflect.Struct && target.Kind() == reflect.Struct &&
+		!src.Type().Implements(textMarshalerType):
+		err = sm.copyStruct(st, src, target)
+	case (src.Kind() == reflect.Slice || src.Kind() == reflect.Array) &&
+		(target.Kind() == reflect.Slice || target.Kind() == reflect.Array):
+		err = sm.copySlice(st, src, target)
+	case src.Kind() == reflect.Map && target.Kind() == reflect.Map:
+		err = sm.copyMap(st, src, target)
+	default:
+		return sm.copyLeaf(st, src, dst)
+	}
+
+	if err == nil && t.Kind() == reflect.Ptr {
+		dst.Set(target.Addr())
+	}
+	return err
+}
+
+// copyLeaf converts src to the type of dst via its representation returned by ToMap
+func (sm *Mapper) copyLeaf(st *state, src reflect.Value, dst reflect.Value) error {
+	mapped, err := sm.mapValue(st, src.Interface(), src)
+	if err != nil {
+		return err
+	}
+	return sm.unmapValue(st, mapped, dst, dst.Type())
+}
+
+// copyStruct copies the fields of the struct src onto the fields of the struct dst with the same names.
+// dst is only modified if no error occurred.
+func (sm *Mapper) copyStruct(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	srcFields := sm.cachedFields(src.Type())
+	dstFields := sm.cachedFields(dst.Type())
+	for _, fields := range []*structFields{srcFields, dstFields} {
+		for _, tagErr := range fields.errs {
+			err = appendErrors(err, st.tagError(tagErr))
+			if st.done() {
+				return
+			}
+		}
+	}
+
+	if err = sm.countElements(st, len(srcFields.list)); err != nil {
+		return
+	}
+
+	modifiedFields := make(map[int]reflect.Value, len(dstFields.list))
+	for i, f := range dstFields.list {
+		j, ok := srcFields.byName[f.name]
+		if !ok {
+			// No such field in src, leave it untouched
+			continue
+		}
+		srcField := srcFields.list[j]
+
+		srcV, ok := fieldByIndex(src, srcField.index)
+		if !ok || (srcField.omitEmpty && IsNilOrEmpty(srcV.Interface(), srcV)) {
+			// Field is omitted in src, like it would be by ToMap
+			continue
+		}
+
+		if checkOpaqueKind(sm.kindPolicy, f.typ) == errSkipValue {
+			// Field is skipped due to the KindPolicy, leave it untouched
+			continue
+		}
+
+		if f.typ.Kind() == reflect.Interface {
+			// Setting interfaces is unsupported.
+			err = appendErrors(err, st.newFieldError(st.childPath(f.name), f.goName, f.typ, srcV.Type(),
+				ErrFieldIsInterface))
+			if st.done() {
+				return
+			}
+			continue
+		}
+
+		if allocErr := checkFieldByIndex(dst, f.index); allocErr != nil {
+			err = appendErrors(err, st.newFieldError(st.childPath(f.name), f.goName, f.typ, srcV.Type(),
+				allocErr))
+			if st.done() {
+				return
+			}
+			continue
+		}
+
+		targetV := reflect.New(f.typ).Elem()
+		st.push(f.name)
+		leave := st.enterField(f.goName)
+		copyErr := sm.copyValue(st, srcV, targetV)
+		if copyErr != nil {
+			copyErr = st.fieldError(copyErr, f.typ, srcV.Interface())
+		}
+		leave()
+		st.pop()
+		if copyErr == errSkipValue {
+			continue
+		} else if copyErr != nil {
+			err = appendErrors(err, copyErr)
+			if st.done() {
+				return
+			}
+			continue
+		}
+		modifiedFields[i] = targetV
+	}
+
+	if err == nil {
+		allocated := make(map[uintptr]bool)
+		for i, f := range dstFields.list {
+			if fieldValue, ok := modifiedFields[i]; ok {
+				st.setFieldByIndex(dst, f.index, fieldValue, allocated)
+			}
+		}
+	}
+	return
+}
+
+// copySlice copies the elements of the slice or array src onto the slice or array dst
+func (sm *Mapper) copySlice(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	if src.Kind() == reflect.Slice && src.IsNil() && dst.Kind() == reflect.Slice {
+		dst.Set(reflect.Zero(dst.Type()))
+		return
+	}
+
+	if err = sm.checkLength(st, src.Len()); err != nil {
+		return
+	}
+
+	var out reflect.Value
+	if dst.Kind() == reflect.Slice {
+		out = reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+	} else if src.Len() > dst.Len() {
+		return ErrArrayTooLong
+	} else {
+		out = reflect.New(dst.Type()).Elem()
+	}
+
+	for i := 0; i < src.Len(); i++ {
+		srcElem := src.Index(i)
+
+		st.pushIndex(i)
+		copyErr := sm.copyValue(st, srcElem, out.Index(i))
+		if copyErr != nil {
+			copyErr = st.fieldError(copyErr, out.Index(i).Type(), srcElem.Interface())
+		}
+		st.pop()
+		if copyErr == errSkipValue {
+			// Element is skipped due to the KindPolicy
+			out.Index(i).Set(reflect.Zero(out.Index(i).Type()))
+			continue
+		} else if copyErr != nil {
+			err = appendErrors(err, copyErr)
+			if st.done() {
+				return
+			}
+		}
+	}
+
+	if err == nil {
+		dst.Set(out)
+	}
+	return
+}
+
+// copyMap copies the entries of the map src onto the map dst
+func (sm *Mapper) copyMap(st *state, src reflect.Value, dst reflect.Value) (err error) {
+	if src.IsNil() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return
+	}
+
+	if err = sm.checkLength(st, src.Len()); err != nil {
+		return
+	}
+
+	t := dst.Type()
+	out := reflect.MakeMapWithSize(t, src.Len())
+
+	// Process the entries sorted by key, so errors are reported in a deterministic order
+	for _, srcKey := range sortedMapKeys(src) {
+		srcValue := src.MapIndex(srcKey)
+
+		st.push(fmt.Sprint(srcKey.Interface()))
+		outKey := reflect.New(t.Key()).Elem()
+		copyErr := sm.copyValue(st, srcKey, outKey)
+		if copyErr != nil {
+			copyErr = st.fieldError(copyErr, t.Key(), srcKey.Interface())
+		}
+
+		outValue := reflect.New(t.Elem()).Elem()
+		if copyErr == nil {
+			if copyErr = sm.copyValue(st, srcValue, outValue); copyErr != nil {
+				copyErr = st.fieldError(copyErr, t.Elem(), srcValue.Interface())
+			}
+		}
+		st.pop()
+
+		if copyErr == errSkipValue {
+			// Entry is skipped due to the KindPolicy
+			continue
+		} else if copyErr != nil {
+			err = appendErrors(err, copyErr)
+			if st.done() {
+				return
+			}
+			continue
+		}
+		out.SetMapIndex(outKey, outValue)
+	}
+
+	if err == nil {
+		dst.Set(out)
+	}
+	return
+}
+
+func (sm *Mapper) copy(src interface{}, dst interface{}) error {
+	dstV := reflect.ValueOf(dst)
+	if dstV.Kind() != reflect.Ptr || dstV.IsNil() || dstV.Elem().Kind() != reflect.Struct {
+		return ErrNotAStructPointer
+	}
+
+	// Values are always copied onto new values, merge mode is not enabled
+	st := sm.newState()
+	st.pointers = newPointerState()
+	if sm.references {
+		st.references = newReferenceState()
+	}
+
+	srcV := reflect.ValueOf(src)
+	if srcV.Kind() == reflect.Ptr && !srcV.IsNil() {
+		// Register the root pointer, so cycles can be detected
+		st.pointers.visiting[ptrKey{ptr: srcV.Pointer(), typ: srcV.Type()}] = 0
+		srcV = srcV.Elem()
+	}
+	if srcV.Kind() != reflect.Struct {
+		return ErrNotAStruct
+	}
+
+	// Copy onto a copy of the target, which is only applied if no error occurred
+	v := dstV.Elem()
+	targetV := reflect.New(v.Type()).Elem()
+	targetV.Set(v)
+
+	if err := sm.copyStruct(st, srcV, targetV); err != nil {
+		if st.aborted() {
+			// Return the error which aborted the call as-is
+			return st.abortErr
+		}
+		return err
+	}
+
+	v.Set(targetV)
+	st.commit()
+	return nil
+}
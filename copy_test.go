@@ -0,0 +1,150 @@
+package structmapper_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructCopyAddress struct {
+	Street string `mapper:"street"`
+	Zip    int    `mapper:"zip"`
+}
+
+type MapperTestStructCopyAudit struct {
+	CreatedBy string    `mapper:"created_by"`
+	Created   time.Time `mapper:"created"`
+}
+
+type mapperTestStructCopyModel struct {
+	MapperTestStructCopyAudit
+	ID      int64                        `mapper:"id"`
+	Name    string                       `mapper:"name"`
+	IP      net.IP                       `mapper:"ip"`
+	Address *mapperTestStructCopyAddress `mapper:"address"`
+	Tags    []string                     `mapper:"tags"`
+	Scores  map[string]int               `mapper:"scores"`
+	Secret  string                       `mapper:"-"`
+	Note    string                       `mapper:"note,omitempty"`
+}
+
+type mapperTestStructCopyDTOAddress struct {
+	Street string `mapper:"street"`
+	Zip    uint16 `mapper:"zip"`
+}
+
+type mapperTestStructCopyDTO struct {
+	ID        int                            `mapper:"id"`
+	Name      string                         `mapper:"name"`
+	CreatedBy string                         `mapper:"created_by"`
+	Created   string                         `mapper:"created"`
+	IP        string                         `mapper:"ip"`
+	Address   mapperTestStructCopyDTOAddress `mapper:"address"`
+	Tags      [3]string                      `mapper:"tags"`
+	Scores    map[string]float64             `mapper:"scores"`
+	Secret    string                         `mapper:"secret"`
+	Note      string                         `mapper:"note"`
+}
+
+func TestMapper_Copy(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("OK", func(t *testing.T) {
+		source := &mapperTestStructCopyModel{
+			MapperTestStructCopyAudit: MapperTestStructCopyAudit{
+				CreatedBy: "admin",
+				Created:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+			ID:   42,
+			Name: "name",
+			IP:   net.IPv4(192, 168, 0, 1),
+			Address: &mapperTestStructCopyAddress{
+				Street: "street",
+				Zip:    1234,
+			},
+			Tags: []string{"a", "b"},
+			Scores: map[string]int{
+				"x": 1,
+			},
+			Secret: "secret",
+		}
+		target := &mapperTestStructCopyDTO{
+			Secret: "untouched",
+			Note:   "untouched",
+		}
+		require.NoError(t, sm.Copy(source, target))
+		require.EqualValues(t, &mapperTestStructCopyDTO{
+			ID:        42,
+			Name:      "name",
+			CreatedBy: "admin",
+			Created:   "2021-01-02T03:04:05Z",
+			IP:        "192.168.0.1",
+			Address: mapperTestStructCopyDTOAddress{
+				Street: "street",
+				Zip:    1234,
+			},
+			Tags: [3]string{"a", "b"},
+			Scores: map[string]float64{
+				"x": 1,
+			},
+			Secret: "untouched",
+			Note:   "untouched",
+		}, target)
+
+		// Nothing is shared with the source
+		source.Scores["y"] = 2
+		require.Len(t, target.Scores, 1)
+
+		// The result is the same as going through ToMap and ToStruct
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		expected := &mapperTestStructCopyModel{}
+		require.NoError(t, sm.ToStruct(m, expected))
+
+		copied := &mapperTestStructCopyModel{}
+		require.NoError(t, sm.Copy(source, copied))
+		require.EqualValues(t, expected, copied)
+		require.False(t, source.Address == copied.Address)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		source := &mapperTestStructCopyModel{
+			Name: "name",
+			Address: &mapperTestStructCopyAddress{
+				Street: "street",
+				Zip:    100000,
+			},
+			Tags: []string{"a", "b", "c", "d"},
+		}
+
+		target := &mapperTestStructCopyDTO{}
+		err := sm.Copy(source, target)
+		fieldErrors := requireFieldErrors(t, err, "tags")
+		require.True(t, errors.Is(fieldErrors["tags"], structmapper.ErrArrayTooLong))
+		require.EqualValues(t, &mapperTestStructCopyDTO{}, target)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		root := &mapperTestStructNode{Name: "root"}
+		root.Children = []*mapperTestStructNode{{Name: "child", Parent: root}}
+
+		target := &mapperTestStructNode{}
+		err := sm.Copy(root, target)
+		var cycleErr *structmapper.CycleError
+		require.True(t, errors.As(err, &cycleErr), "returned error does not contain a *CycleError")
+		require.EqualValues(t, "children[0].parent", cycleErr.Path())
+		require.EqualValues(t, &mapperTestStructNode{}, target)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		require.EqualError(t, sm.Copy(&mapperTestStructCopyModel{}, mapperTestStructCopyDTO{}),
+			structmapper.ErrNotAStructPointer.Error())
+		require.EqualError(t, sm.Copy("test", &mapperTestStructCopyDTO{}), structmapper.ErrNotAStruct.Error())
+	})
+}
@@ -0,0 +1,279 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// This file contains the diff functionality of Mapper
+
+// ChangeKind designates the kind of a Change
+type ChangeKind int
+
+const (
+	// ChangeAdded designates a value which only exists in the new struct
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved designates a value which only exists in the old struct
+	ChangeRemoved
+	// ChangeModified designates a value which differs between the old and the new struct
+	ChangeModified
+)
+
+// String returns the name of the change kind
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change describes a single difference between two structs, as returned by Diff
+type Change struct {
+	// Path is the path of the value, like "servers[2].tls.cert"
+	Path string
+	// Kind is the kind of the change
+	Kind ChangeKind
+	// Old is the mapped value in the old struct, nil if the value has been added
+	Old interface{}
+	// New is the mapped value in the new struct, nil if the value has been removed
+	New interface{}
+}
+
+// diffValue compares the mapped values old and new of type t, which is nil if unknown, and reports their
+// differences.
+// key is the key identifying the elements if old and new are slices.
+func (sm *Mapper) diffValue(st *state, t reflect.Type, key string, old, new interface{}) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch oldV := old.(type) {
+	case map[string]interface{}:
+		if newV, ok := new.(map[string]interface{}); ok {
+			if t != nil && t.Kind() == reflect.Struct {
+				sm.diffStruct(st, t, oldV, newV)
+				return
+			}
+			sm.diffMap(st, t, reflect.ValueOf(oldV), reflect.ValueOf(newV))
+			return
+		}
+	case map[interface{}]interface{}:
+		if newV, ok := new.(map[interface{}]interface{}); ok {
+			sm.diffMap(st, t, reflect.ValueOf(oldV), reflect.ValueOf(newV))
+			return
+		}
+	case []interface{}:
+		if newV, ok := new.([]interface{}); ok {
+			var elemT reflect.Type
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				elemT = t.Elem()
+			}
+			if key == "" || st.diff.byIndex || !sm.diffKeyedSlice(st, elemT, key, oldV, newV) {
+				sm.diffSlice(st, elemT, oldV, newV)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		st.diff.onChange(ChangeModified, old, new)
+	}
+}
+
+// diffStruct compares the maps old and new, which a struct of type t has been mapped to
+func (sm *Mapper) diffStruct(st *state, t reflect.Type, old, new map[string]interface{}) {
+	fields := sm.cachedFields(t)
+
+	names := make([]string, 0, len(old)+len(new))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var fieldT reflect.Type
+		var key string
+		if i, ok := fields.byName[name]; ok {
+			fieldT, key = fields.list[i].typ, fields.list[i].key
+		}
+
+		oldValue, newValue := reflect.ValueOf(old).MapIndex(reflect.ValueOf(name)),
+			reflect.ValueOf(new).MapIndex(reflect.ValueOf(name))
+
+		st.push(name)
+		sm.diffEntry(st, fieldT, key, oldValue, newValue)
+		st.pop()
+	}
+}
+
+// diffMap compares the maps old and new, which a map of type t has been mapped to
+func (sm *Mapper) diffMap(st *state, t reflect.Type, old, new reflect.Value) {
+	var elemT reflect.Type
+	if t != nil && t.Kind() == reflect.Map {
+		elemT = t.Elem()
+	}
+
+	keys := old.MapKeys()
+	for _, k := range new.MapKeys() {
+		if !old.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return lessMapKey(keys[i], keys[j])
+	})
+
+	for _, k := range keys {
+		oldValue, newValue := old.MapIndex(k), new.MapIndex(k)
+
+		st.push(fmt.Sprint(k.Interface()))
+		sm.diffEntry(st, elemT, "", oldValue, newValue)
+		st.pop()
+	}
+}
+
+// diffEntry compares the map entries old and new, which are invalid if the entry does not exist
+func (sm *Mapper) diffEntry(st *state, t reflect.Type, key string, old, new reflect.Value) {
+	switch {
+	case !old.IsValid():
+		st.diff.onChange(ChangeAdded, nil, new.Interface())
+	case !new.IsValid():
+		st.diff.onChange(ChangeRemoved, old.Interface(), nil)
+	default:
+		sm.diffValue(st, t, key, old.Interface(), new.Interface())
+	}
+}
+
+// diffSlice compares the slices old and new by index
+func (sm *Mapper) diffSlice(st *state, elemT reflect.Type, old, new []interface{}) {
+	for i := 0; i < len(old) || i < len(new); i++ {
+		st.pushIndex(i)
+		switch {
+		case i >= len(old):
+			st.diff.onChange(ChangeAdded, nil, new[i])
+		case i >= len(new):
+			st.diff.onChange(ChangeRemoved, old[i], nil)
+		default:
+			sm.diffValue(st, elemT, "", old[i], new[i])
+		}
+		st.pop()
+	}
+}
+
+// diffKeyedSlice compares the slices old and new, matching their elements by the value of key.
+// Modified and added elements are reported at their index in new, removed elements at their index in old.
+// false is returned if any element is not a map containing key, or if a key value is not unique.
+func (sm *Mapper) diffKeyedSlice(st *state, elemT reflect.Type, key string, old, new []interface{}) bool {
+	oldIndex, ok := keyedIndex(key, old)
+	if !ok {
+		return false
+	}
+	newIndex, ok := keyedIndex(key, new)
+	if !ok {
+		return false
+	}
+
+	for i, value := range new {
+		st.pushIndex(i)
+		if j, ok := oldIndex[fmt.Sprint(value.(map[string]interface{})[key])]; ok {
+			sm.diffValue(st, elemT, "", old[j], value)
+		} else {
+			st.diff.onChange(ChangeAdded, nil, value)
+		}
+		st.pop()
+	}
+
+	for i, value := range old {
+		if _, ok := newIndex[fmt.Sprint(value.(map[string]interface{})[key])]; !ok {
+			st.pushIndex(i)
+			st.diff.onChange(ChangeRemoved, value, nil)
+			st.pop()
+		}
+	}
+
+	return true
+}
+
+// keyedIndex returns the indexes of the elements of s by the string representation of their value of key
+func keyedIndex(key string, s []interface{}) (map[string]int, bool) {
+	index := make(map[string]int, len(s))
+	for i, value := range s {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		keyValue, ok := m[key]
+		if !ok {
+			return nil, false
+		}
+
+		k := fmt.Sprint(keyValue)
+		if _, ok := index[k]; ok {
+			return nil, false
+		}
+		index[k] = i
+	}
+	return index, true
+}
+
+// diffRoot maps the struct s for comparison.
+// A nil pointer to a struct is mapped to an empty map, so all values of the other struct are reported as
+// added or removed.
+func (sm *Mapper) diffRoot(s interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && v.IsNil() && v.Type().Elem().Kind() == reflect.Struct {
+		return map[string]interface{}{}, nil
+	}
+	return sm.toMap(s)
+}
+
+// diffRoots compares the structs a and b, which have to be of the same type, and reports their differences
+func (sm *Mapper) diffRoots(st *state, a, b interface{}) error {
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) {
+		return ErrTypeMismatch
+	}
+	if t == nil {
+		return nil
+	}
+
+	old, err := sm.diffRoot(a)
+	if err != nil {
+		return err
+	}
+	new, err := sm.diffRoot(b)
+	if err != nil {
+		return err
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	sm.diffStruct(st, t, old, new)
+	return nil
+}
+
+func (sm *Mapper) diff(a, b interface{}) ([]Change, error) {
+	var changes []Change
+
+	st := sm.newState()
+	st.diff.onChange = func(kind ChangeKind, old, new interface{}) {
+		changes = append(changes, Change{Path: st.pathString(), Kind: kind, Old: old, New: new})
+	}
+
+	if err := sm.diffRoots(st, a, b); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
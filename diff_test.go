@@ -0,0 +1,152 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructDiff struct {
+	Name    string                    `mapper:"name"`
+	Tags    []string                  `mapper:"tags"`
+	Servers []*mapperTestStructServer `mapper:"servers,key=name"`
+	Backups []mapperTestStructServer  `mapper:"backups"`
+	Labels  map[string]string         `mapper:"labels"`
+	Primary *mapperTestStructServer   `mapper:"primary"`
+}
+
+// newMapperTestStructDiff returns a new value to compare, as the tests modify both sides
+func newMapperTestStructDiff() *mapperTestStructDiff {
+	return &mapperTestStructDiff{
+		Name: "name",
+		Tags: []string{"a", "b"},
+		Servers: []*mapperTestStructServer{
+			{Name: "web", Port: 80},
+			{Name: "db", Port: 5432},
+			{Name: "cache", Port: 6379},
+		},
+		Backups: []mapperTestStructServer{
+			{Name: "backup", Port: 22},
+		},
+		Labels: map[string]string{
+			"env":  "dev",
+			"team": "a",
+		},
+		Primary: &mapperTestStructServer{Name: "primary", Port: 1},
+	}
+}
+
+func TestMapper_Diff(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("Equal", func(t *testing.T) {
+		changes, err := sm.Diff(newMapperTestStructDiff(), newMapperTestStructDiff())
+		require.NoError(t, err)
+		require.Len(t, changes, 0)
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		a := newMapperTestStructDiff()
+		a.Tags = append(a.Tags, "c")
+		b := newMapperTestStructDiff()
+		b.Name = "new"
+		b.Tags = []string{"a"}
+		// Elements are matched by name, regardless of their order
+		b.Servers = []*mapperTestStructServer{
+			{Name: "db", Port: 5433, Proxy: "proxy"},
+			{Name: "web", Port: 80},
+			{Name: "mail", Port: 25},
+		}
+		b.Backups[0].Port = 2222
+		b.Labels["env"] = "prod"
+		delete(b.Labels, "team")
+		b.Labels["owner"] = "b"
+		b.Primary = nil
+
+		changes, err := sm.Diff(a, b)
+		require.NoError(t, err)
+		require.EqualValues(t, []structmapper.Change{
+			{Path: "backups[0].port", Kind: structmapper.ChangeModified, Old: 22, New: 2222},
+			{Path: "labels.env", Kind: structmapper.ChangeModified, Old: "dev", New: "prod"},
+			{Path: "labels.owner", Kind: structmapper.ChangeAdded, New: "b"},
+			{Path: "labels.team", Kind: structmapper.ChangeRemoved, Old: "a"},
+			{Path: "name", Kind: structmapper.ChangeModified, Old: "name", New: "new"},
+			{
+				Path: "primary",
+				Kind: structmapper.ChangeModified,
+				Old:  map[string]interface{}{"name": "primary", "port": 1},
+				New:  nil,
+			},
+			{Path: "servers[0].port", Kind: structmapper.ChangeModified, Old: 5432, New: 5433},
+			{Path: "servers[0].proxy", Kind: structmapper.ChangeAdded, New: "proxy"},
+			{
+				Path: "servers[2]",
+				Kind: structmapper.ChangeAdded,
+				New:  map[string]interface{}{"name": "mail", "port": 25},
+			},
+			{
+				Path: "servers[2]",
+				Kind: structmapper.ChangeRemoved,
+				Old:  map[string]interface{}{"name": "cache", "port": 6379},
+			},
+			{Path: "tags[1]", Kind: structmapper.ChangeRemoved, Old: "b"},
+			{Path: "tags[2]", Kind: structmapper.ChangeRemoved, Old: "c"},
+		}, changes)
+	})
+
+	t.Run("DuplicateKeys", func(t *testing.T) {
+		// Slices with duplicate keys are compared by index
+		a := &mapperTestStructDiff{
+			Servers: []*mapperTestStructServer{
+				{Name: "web", Port: 80},
+			},
+		}
+		b := &mapperTestStructDiff{
+			Servers: []*mapperTestStructServer{
+				{Name: "other", Port: 80},
+				{Name: "other", Port: 80},
+			},
+		}
+
+		changes, err := sm.Diff(a, b)
+		require.NoError(t, err)
+		require.EqualValues(t, []structmapper.Change{
+			{Path: "servers[0].name", Kind: structmapper.ChangeModified, Old: "web", New: "other"},
+			{
+				Path: "servers[1]",
+				Kind: structmapper.ChangeAdded,
+				New:  map[string]interface{}{"name": "other", "port": 80},
+			},
+		}, changes)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		changes, err := sm.Diff((*mapperTestStructSimple)(nil), &mapperTestStructSimple{A: "a"})
+		require.NoError(t, err)
+		require.EqualValues(t, []structmapper.Change{
+			{Path: "eff", Kind: structmapper.ChangeAdded, New: "a"},
+		}, changes)
+	})
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		changes, err := sm.Diff(&mapperTestStructSimple{}, mapperTestStructSimple{})
+		require.EqualError(t, err, structmapper.ErrTypeMismatch.Error())
+		require.Nil(t, changes)
+	})
+
+	t.Run("NotAStruct", func(t *testing.T) {
+		changes, err := sm.Diff("a", "b")
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+		require.Nil(t, changes)
+	})
+}
+
+func TestChangeKind_String(t *testing.T) {
+	require.EqualValues(t, "added", structmapper.ChangeAdded.String())
+	require.EqualValues(t, "removed", structmapper.ChangeRemoved.String())
+	require.EqualValues(t, "modified", structmapper.ChangeModified.String())
+	require.EqualValues(t, "ChangeKind(0)", structmapper.ChangeKind(0).String())
+}
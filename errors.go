@@ -1,6 +1,11 @@
 package structmapper
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 var (
 	// ErrTagNameEmpty designates that the passed tag name is empty
@@ -20,4 +25,209 @@ var (
 
 	// ErrNotAStructPointer designates that the passed value is not a pointer to a struct
 	ErrNotAStructPointer = errors.New("Not a struct pointer")
+
+	// ErrUnexportedEmbeddedPtr designates that a nil pointer to an unexported embedded struct
+	// would have to be allocated
+	ErrUnexportedEmbeddedPtr = errors.New("Cannot set embedded pointer to unexported struct")
+
+	// ErrNegativeLimit designates that the passed limit is negative
+	ErrNegativeLimit = errors.New("Limit is negative")
+
+	// ErrInvalidKindPolicy designates that the passed KindPolicy is invalid
+	ErrInvalidKindPolicy = errors.New("Invalid kind policy")
+
+	// ErrInvalidSliceStrategy designates that the passed SliceStrategy is invalid
+	ErrInvalidSliceStrategy = errors.New("Invalid slice strategy")
+
+	// ErrUnsupportedKind designates that a channel, function or unsafe.Pointer value has been
+	// rejected due to KindPolicyError
+	ErrUnsupportedKind = errors.New("Unsupported kind")
+
+	// ErrNotASlice designates that the passed value is not a slice or array
+	ErrNotASlice = errors.New("Not a slice")
+
+	// ErrNotASlicePointer designates that the passed value is not a pointer to a slice
+	ErrNotASlicePointer = errors.New("Not a slice pointer")
+
+	// ErrNegativeWorkers designates that the passed number of workers is negative
+	ErrNegativeWorkers = errors.New("Number of workers is negative")
+
+	// ErrArrayTooLong designates that the source contains more elements than the target array holds
+	ErrArrayTooLong = errors.New("Too many elements for array")
+
+	// ErrTypeMismatch designates that the passed values are not of the same type
+	ErrTypeMismatch = errors.New("Types do not match")
+
+	// ErrInvalidJSONPointer designates that the passed JSON pointer is malformed
+	ErrInvalidJSONPointer = errors.New("Invalid JSON pointer")
+
+	// ErrInvalidPath designates that the passed path is malformed
+	ErrInvalidPath = errors.New("Invalid path")
+
+	// ErrPathNotFound designates that the passed path does not refer to an existing value
+	ErrPathNotFound = errors.New("Path not found")
+
+	// ErrInvalidJSONPatchOp designates that the passed JSON patch operation is invalid
+	ErrInvalidJSONPatchOp = errors.New("Invalid JSON patch operation")
+
+	// ErrJSONPatchTestFailed designates that the value of a JSON patch test operation does not match
+	ErrJSONPatchTestFailed = errors.New("JSON patch test failed")
 )
+
+var _ error = (*FieldError)(nil)
+
+// FieldError is an error that indicates that mapping the value at a specific path failed.
+// The underlying cause can be retrieved using errors.Unwrap, errors.Is and errors.As.
+type FieldError struct {
+	path      string
+	field     string
+	typ       reflect.Type
+	valueType reflect.Type
+	err       error
+}
+
+// Error returns the error string and causes FieldError to implement the error interface
+func (fe *FieldError) Error() string {
+	if fe.path == "" {
+		return fe.err.Error()
+	}
+	return fe.path + ": " + fe.err.Error()
+}
+
+// Unwrap returns the cause of the error
+func (fe *FieldError) Unwrap() error {
+	return fe.err
+}
+
+// Path returns the full path of the value, like "servers[2].tls.cert"
+func (fe *FieldError) Path() string {
+	return fe.path
+}
+
+// Field returns the name of the Go struct field the value belongs to.
+// For slice, array and map elements, this is the struct field holding the slice, array or map.
+func (fe *FieldError) Field() string {
+	return fe.field
+}
+
+// Type returns the Go type of the value, which is the expected type when mapping onto a struct
+func (fe *FieldError) Type() reflect.Type {
+	return fe.typ
+}
+
+// ValueType returns the type of the received value, or nil if the value is nil
+func (fe *FieldError) ValueType() reflect.Type {
+	return fe.valueType
+}
+
+func newErrorField(path, field string, typ, valueType reflect.Type, err error) error {
+	return &FieldError{
+		path:      path,
+		field:     field,
+		typ:       typ,
+		valueType: valueType,
+		err:       err,
+	}
+}
+
+// IsFieldError checks if the given error is a FieldError
+// and returns the FieldError along with a boolean that defines
+// if it is indeed a field error.
+// The returned *FieldError may be nil, if the flag is false
+func IsFieldError(err error) (*FieldError, bool) {
+	fe, ok := err.(*FieldError)
+	return fe, ok
+}
+
+var _ error = (*MultiError)(nil)
+
+// MultiError is an error that aggregates multiple errors, like the errors of all fields which could
+// not be mapped.
+// errors.Is and errors.As match any of the aggregated errors.
+type MultiError struct {
+	errs []error
+}
+
+// Error returns the error string and causes MultiError to implement the error interface
+func (me *MultiError) Error() string {
+	points := make([]string, len(me.errs))
+	for i, err := range me.errs {
+		points[i] = fmt.Sprintf("* %s", err)
+	}
+
+	return fmt.Sprintf("%d error(s) occurred:\n\n%s", len(me.errs), strings.Join(points, "\n"))
+}
+
+// Errors returns the aggregated errors
+func (me *MultiError) Errors() []error {
+	return me.errs
+}
+
+// Unwrap returns the aggregated errors
+func (me *MultiError) Unwrap() []error {
+	return me.errs
+}
+
+// IsMultiError checks if the given error is a MultiError
+// and returns the MultiError along with a boolean that defines
+// if it is indeed a multi error.
+// The returned *MultiError may be nil, if the flag is false
+func IsMultiError(err error) (*MultiError, bool) {
+	me, ok := err.(*MultiError)
+	return me, ok
+}
+
+// appendErrors appends errs to err, turning err into a MultiError if it is not one already.
+// MultiErrors contained in errs are flattened.
+func appendErrors(err error, errs ...error) *MultiError {
+	me, ok := err.(*MultiError)
+	if !ok || me == nil {
+		me = &MultiError{}
+		if err != nil {
+			me.errs = append(me.errs, err)
+		}
+	}
+
+	for _, e := range errs {
+		if child, ok := e.(*MultiError); ok {
+			me.errs = append(me.errs, child.errs...)
+		} else {
+			me.errs = append(me.errs, e)
+		}
+	}
+	return me
+}
+
+// prefixErrorPaths returns err with the paths of FieldErrors, LimitErrors, CycleErrors and UnknownKeysErrors,
+// including the ones aggregated by a MultiError, being relative to the path prefix.
+// Other errors are wrapped into a FieldError holding the prefix.
+func prefixErrorPaths(err error, prefix string) error {
+	switch e := err.(type) {
+	case *FieldError:
+		prefixed := *e
+		prefixed.path = joinPath(prefix, e.path)
+		return &prefixed
+	case *LimitError:
+		prefixed := *e
+		prefixed.path = joinPath(prefix, e.path)
+		return &prefixed
+	case *CycleError:
+		prefixed := *e
+		prefixed.path = joinPath(prefix, e.path)
+		prefixed.ref = joinPath(prefix, e.ref)
+		return &prefixed
+	case *UnknownKeysError:
+		keys := make([]string, len(e.keys))
+		for i, key := range e.keys {
+			keys[i] = joinPath(prefix, key)
+		}
+		return newErrorUnknownKeys(keys)
+	case *MultiError:
+		errs := make([]error, len(e.errs))
+		for i, child := range e.errs {
+			errs[i] = prefixErrorPaths(child, prefix)
+		}
+		return &MultiError{errs: errs}
+	}
+	return newErrorField(prefix, "", nil, nil, err)
+}
@@ -0,0 +1,266 @@
+package structmapper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructFieldErrorServer struct {
+	mapperTestStructServer
+	Ports map[string]int `mapper:"ports"`
+	Hosts [1]string      `mapper:"hosts"`
+}
+
+type mapperTestStructFieldError struct {
+	Servers []mapperTestStructFieldErrorServer `mapper:"servers"`
+	Invalid string                             `mapper:"invalid,"`
+}
+
+// requireFieldErrors checks that err holds a FieldError per path and returns them by path
+func requireFieldErrors(t *testing.T, err error, paths ...string) map[string]*structmapper.FieldError {
+	require.Error(t, err)
+	me, ok := structmapper.IsMultiError(err)
+	require.EqualValues(t, true, ok, "returned error is not a *MultiError")
+
+	fieldErrors := make(map[string]*structmapper.FieldError)
+	for _, e := range me.Errors() {
+		var fe *structmapper.FieldError
+		require.True(t, errors.As(e, &fe), "%v is not a *FieldError", e)
+		fieldErrors[fe.Path()] = fe
+	}
+
+	require.Len(t, fieldErrors, len(paths))
+	for _, path := range paths {
+		require.Contains(t, fieldErrors, path)
+	}
+	return fieldErrors
+}
+
+func TestFieldError(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("ToStruct", func(t *testing.T) {
+		target := &mapperTestStructFieldError{}
+		err := sm.ToStruct(map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{},
+				map[string]interface{}{
+					"tls": map[string]interface{}{
+						"cert": 1.5,
+					},
+					"ports": map[string]interface{}{
+						"http": "80",
+					},
+					"hosts": []interface{}{"a", "b"},
+				},
+			},
+		}, target)
+
+		fieldErrors := requireFieldErrors(t, err, "Invalid", "servers[1].tls.cert", "servers[1].ports.http",
+			"servers[1].hosts")
+
+		fe := fieldErrors["servers[1].tls.cert"]
+		require.EqualValues(t, "Cert", fe.Field())
+		require.EqualValues(t, reflect.TypeOf(""), fe.Type())
+		require.EqualValues(t, reflect.TypeOf(1.5), fe.ValueType())
+		require.EqualError(t, fe, "servers[1].tls.cert: Type mismatch: string and float64 are incompatible")
+
+		fe = fieldErrors["servers[1].ports.http"]
+		require.EqualValues(t, "Ports", fe.Field())
+		require.EqualValues(t, reflect.TypeOf(0), fe.Type())
+		require.EqualValues(t, reflect.TypeOf(""), fe.ValueType())
+
+		fe = fieldErrors["servers[1].hosts"]
+		require.EqualValues(t, "Hosts", fe.Field())
+		require.True(t, errors.Is(fe, structmapper.ErrArrayTooLong))
+
+		fe = fieldErrors["Invalid"]
+		require.EqualValues(t, "Invalid", fe.Field())
+		_, ok := structmapper.IsInvalidTag(errors.Unwrap(fe))
+		require.True(t, ok)
+
+		require.Empty(t, target.Servers)
+	})
+
+	t.Run("ToMap", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		_, err = sm.ToMap(&mapperTestStructOpaque{
+			S: []interface{}{"s0", func() {}},
+		})
+		fieldErrors := requireFieldErrors(t, err, "c", "f", "p", "s[1]")
+
+		fe := fieldErrors["s[1]"]
+		require.EqualValues(t, "S", fe.Field())
+		require.EqualValues(t, reflect.TypeOf((*interface{})(nil)).Elem(), fe.Type())
+		require.EqualValues(t, reflect.TypeOf(func() {}), fe.ValueType())
+		require.True(t, errors.Is(fe, structmapper.ErrUnsupportedKind))
+	})
+
+	t.Run("IsFieldError", func(t *testing.T) {
+		fe, ok := structmapper.IsFieldError(structmapper.ErrNotAStruct)
+		require.False(t, ok)
+		require.Nil(t, fe)
+	})
+}
+
+func TestOptionMaxErrors(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionMaxErrors(-1))
+	require.Error(t, err)
+	require.Nil(t, sm)
+}
+
+func TestMapper_MaxErrors(t *testing.T) {
+	requireErrorCount := func(t *testing.T, err error, count int) {
+		me, ok := structmapper.IsMultiError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *MultiError")
+		require.Len(t, me.Errors(), count)
+	}
+
+	newSources := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			// 4 struct fields
+			{
+				"a":   true,
+				"b":   "b",
+				"c":   "c",
+				"dee": "dee",
+			},
+			// 2 slice elements, 1 array element and 2 map entries
+			{
+				"a": []interface{}{true, false},
+				"c": []interface{}{"c0", true},
+				"m": map[string]interface{}{
+					"x": "x",
+					"y": "y",
+				},
+			},
+		}
+	}
+	targets := []func() interface{}{
+		func() interface{} { return &mapperTestStructNested{} },
+		func() interface{} {
+			return &struct {
+				mapperTestStructArraySlice
+				M map[string]int `mapper:"m"`
+			}{}
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		options []structmapper.Option
+		counts  []int
+	}{
+		{
+			name:   "CollectAll",
+			counts: []int{4, 5},
+		},
+		{
+			name:    "FailFast",
+			options: []structmapper.Option{structmapper.OptionFailFast(true)},
+			counts:  []int{1, 1},
+		},
+		{
+			name:    "MaxErrors",
+			options: []structmapper.Option{structmapper.OptionMaxErrors(2)},
+			counts:  []int{2, 2},
+		},
+		{
+			name:    "MaxErrorsAboveCount",
+			options: []structmapper.Option{structmapper.OptionMaxErrors(10)},
+			counts:  []int{4, 5},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sm, err := structmapper.NewMapper(tc.options...)
+			require.NoError(t, err)
+			require.NotNil(t, sm)
+
+			for i, source := range newSources() {
+				requireErrorCount(t, sm.ToStruct(source, targets[i]()), tc.counts[i])
+			}
+		})
+	}
+
+	t.Run("ToMap", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		value := 1
+		source := &mapperTestStructOpaque{
+			C: make(chan bool),
+			F: func() {},
+			P: unsafe.Pointer(&value),
+			S: []interface{}{"s0", func() {}},
+		}
+
+		// c, f, p and s[1] fail
+		_, err = sm.ToMap(source)
+		requireErrorCount(t, err, 4)
+
+		sm, err = structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError),
+			structmapper.OptionFailFast(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		_, err = sm.ToMap(source)
+		requireErrorCount(t, err, 1)
+		require.True(t, errors.Is(err, structmapper.ErrUnsupportedKind))
+	})
+}
+
+func TestMapper_ErrorOrder(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	source := map[string]interface{}{
+		"z": map[string]interface{}{
+			"b": "b",
+			"a": "a",
+			"c": "c",
+		},
+		"a": map[interface{}]interface{}{
+			10: 1.5,
+			2:  2.5,
+			1:  "1",
+		},
+		"bee": map[string]interface{}{
+			// Keys have to be integers
+			"1": 1,
+		},
+	}
+
+	// Struct fields are reported in declaration order, map entries sorted by key
+	expected := []string{
+		"a.2: Type mismatch: string and float64 are incompatible",
+		"a.10: Type mismatch: string and float64 are incompatible",
+		"bee.1: Type mismatch: int and string are incompatible",
+		"z.a: Type mismatch: int and string are incompatible",
+		"z.b: Type mismatch: int and string are incompatible",
+		"z.c: Type mismatch: int and string are incompatible",
+	}
+
+	for i := 0; i < 20; i++ {
+		err := sm.ToStruct(source, &mapperTestStructMap{})
+		me, ok := structmapper.IsMultiError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *MultiError")
+
+		actual := make([]string, 0, len(me.Errors()))
+		for _, e := range me.Errors() {
+			actual = append(actual, e.Error())
+		}
+		require.EqualValues(t, expected, actual)
+	}
+}
@@ -0,0 +1,313 @@
+package structmapper
+
+import (
+	"encoding"
+	"reflect"
+	"sort"
+)
+
+// This file contains the field resolution logic of Mapper
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// field describes a single struct field as seen by Mapper, after the fields of embedded structs
+// have been promoted to the outer struct.
+type field struct {
+	// name is the key the field is mapped to
+	name string
+	// tagged is set if the name has been defined using a tag
+	tagged bool
+	// omitEmpty is set if the field shall be omitted if it is empty
+	omitEmpty bool
+	// index is the index sequence used for looking up the field via reflect
+	index []int
+	// typ is the type of the field
+	typ reflect.Type
+	// goName is the name of the Go struct field
+	goName string
+	// key is the key of the field identifying the elements of a slice, if defined using a tag
+	key string
+}
+
+// structFields holds the resolved fields of a struct type
+type structFields struct {
+	list   []field
+	byName map[string]int
+	// errs holds the errors which occurred while parsing the tags of the struct's fields
+	errs []tagError
+}
+
+// tagError describes an error which occurred while parsing the tag of a struct field
+type tagError struct {
+	// field is the name of the struct field
+	field string
+	// typ is the type of the struct field
+	typ reflect.Type
+	err error
+}
+
+// cachedFields returns the resolved fields of the struct type t.
+// The result is cached for the lifetime of the Mapper.
+func (sm *Mapper) cachedFields(t reflect.Type) *structFields {
+	if f, ok := sm.fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := sm.fieldCache.LoadOrStore(t, sm.typeFields(t))
+	return f.(*structFields)
+}
+
+// typeFields resolves the fields of the struct type t.
+//
+// The fields of embedded structs are promoted to the outer struct following the visibility rules
+// of Go, which are also used by encoding/json:
+// a field at a shallower depth hides fields of the same name at deeper depths, a field with a name
+// defined by a tag beats an untagged field at the same depth and ambiguous fields are dropped.
+func (sm *Mapper) typeFields(t reflect.Type) *structFields {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	// Embedded structs to explore at the current and the next depth
+	current := []embedded{}
+	next := []embedded{{typ: t}}
+
+	// Number of times a type has been queued at the current and the next depth
+	var count, nextCount map[reflect.Type]int
+
+	// Types already visited at a shallower depth
+	visited := map[reflect.Type]bool{}
+
+	sf := &structFields{}
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				fieldD := e.typ.Field(i)
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				fieldT := fieldD.Type
+				if fieldT.Name() == "" && fieldT.Kind() == reflect.Ptr {
+					fieldT = fieldT.Elem()
+				}
+
+				if !fieldD.IsExported() && !(fieldD.Anonymous && fieldT.Kind() == reflect.Struct) {
+					// Ignore private fields
+					continue
+				}
+
+				name, omitEmpty, key, tagErr := parseTag(fieldD.Tag.Get(sm.tagName))
+				if tagErr != nil {
+					// Parsing the tag failed, ignore the field and carry on
+					sf.errs = append(sf.errs, tagError{field: fieldD.Name, typ: fieldD.Type, err: tagErr})
+					continue
+				}
+
+				if name == "-" {
+					// Tag defines that the field shall be ignored
+					continue
+				}
+
+				if fieldD.Anonymous && fieldT.Kind() == reflect.Struct && name == "" &&
+					!fieldD.Type.Implements(textMarshalerType) {
+					// Untagged embedded struct: its fields are resolved at the next depth.
+					// Embedded structs implementing encoding.TextMarshaler, like time.Time, are
+					// mapped as values instead. Note that the embedding struct implements
+					// encoding.TextMarshaler itself then, so it is mapped as text wherever it is nested.
+					nextCount[fieldT]++
+					if nextCount[fieldT] == 1 {
+						next = append(next, embedded{typ: fieldT, index: index})
+					}
+					continue
+				}
+
+				if !fieldD.IsExported() {
+					// Embedded struct of a private type, which cannot be accessed
+					continue
+				}
+
+				tagged := name != ""
+				if !tagged {
+					name = fieldD.Name
+				}
+
+				fields = append(fields, field{
+					name:      name,
+					tagged:    tagged,
+					omitEmpty: omitEmpty,
+					index:     index,
+					typ:       fieldD.Type,
+					goName:    fieldD.Name,
+					key:       key,
+				})
+
+				if count[e.typ] > 1 {
+					// The embedded struct has been reached via multiple paths at the same depth:
+					// add a duplicate, which causes the field to be dropped as ambiguous below
+					fields = append(fields, fields[len(fields)-1])
+				}
+			}
+		}
+	}
+
+	// Sort fields by name, breaking ties with depth, then whether a tag is present,
+	// then index sequence
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// Remove hidden and ambiguous fields
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	// Restore declaration order
+	sf.list = out
+	sort.Slice(sf.list, func(i, j int) bool {
+		return indexLess(sf.list[i].index, sf.list[j].index)
+	})
+
+	sf.byName = make(map[string]int, len(sf.list))
+	for i, f := range sf.list {
+		sf.byName[f.name] = i
+	}
+
+	return sf
+}
+
+// dominantField looks through the fields, all of which are known to have the same name,
+// to find the single field that dominates the others.
+// The fields are expected to be sorted by depth and tag presence.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 &&
+		len(fields[0].index) == len(fields[1].index) &&
+		fields[0].tagged == fields[1].tagged {
+		// Multiple fields at the same depth with the same tag presence: ambiguous
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// indexLess compares two index sequences
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the field of v defined by the given index sequence.
+// If a nil embedded struct pointer is encountered along the way false is returned.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// checkFieldByIndex checks if the field of v defined by the given index sequence can be set
+// using fieldByIndexAlloc, which is not the case if a nil pointer to an unexported embedded
+// struct would have to be allocated.
+func checkFieldByIndex(v reflect.Value, index []int) error {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return ErrUnexportedEmbeddedPtr
+				}
+				// Anything below a pointer we allocate ourselves can be set
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return nil
+}
+
+// fieldByIndexAlloc returns the field of v defined by the given index sequence, allocating
+// nil embedded struct pointers along the way.
+// checkFieldByIndex must be used beforehand to ensure that all pointers can be allocated.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// setFieldByIndex sets the field of v defined by the given index sequence to value, allocating nil
+// pointers to embedded structs as required.
+// Pointers to embedded structs which existed before are shared with the original target, so setting
+// fields behind them is deferred until the whole call succeeded. allocated holds the pointers which
+// have been allocated before and may be modified directly.
+func (st *state) setFieldByIndex(v reflect.Value, index []int, value reflect.Value, allocated map[uintptr]bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+				allocated[v.Pointer()] = true
+			} else if !allocated[v.Pointer()] {
+				existing, rest := v.Elem(), index[i:]
+				st.deferChange(func() {
+					fieldByIndexAlloc(existing, rest).Set(value)
+				})
+				return
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	v.Set(value)
+}
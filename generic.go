@@ -0,0 +1,66 @@
+package structmapper
+
+import "reflect"
+
+// This file contains the generic entry points of Mapper.
+//
+// Go does not allow constraining type parameters to struct types, so the type parameters of these functions
+// cannot be checked at compile time. Instead, all of them check at run time that their type parameter is a
+// struct or a pointer to a struct and return ErrNotAStruct otherwise, including for interface types, before
+// processing any value.
+
+// isStructType checks if t is a struct or a pointer to a struct
+func isStructType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct)
+}
+
+// Decode maps the source map onto a new value of type T and returns it.
+//
+// T has to be a struct or a pointer to a struct.
+func Decode[T any](sm *Mapper, source map[string]interface{}) (T, error) {
+	var result T
+
+	t := reflect.TypeOf(&result).Elem()
+	if !isStructType(t) {
+		return result, ErrNotAStruct
+	}
+
+	if t.Kind() == reflect.Struct {
+		err := sm.ToStruct(source, &result)
+		return result, err
+	}
+
+	target := reflect.New(t.Elem())
+	if err := sm.ToStruct(source, target.Interface()); err != nil {
+		return result, err
+	}
+	return target.Interface().(T), nil
+}
+
+// DecodeSlice maps each of the source maps onto a new value of type T, like Decode does, and returns the
+// values in the same order. See Mapper.ToStructs for details.
+//
+// T has to be a struct or a pointer to a struct.
+func DecodeSlice[T any](sm *Mapper, sources []map[string]interface{}) ([]T, error) {
+	if !isStructType(reflect.TypeOf((*T)(nil)).Elem()) {
+		return nil, ErrNotAStruct
+	}
+
+	var results []T
+	if err := sm.ToStructs(sources, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// EncodeSlice maps each of the source values onto a map[string]interface{} and returns the maps in the
+// same order. See Mapper.ToMaps for details.
+//
+// T has to be a struct or a pointer to a struct.
+func EncodeSlice[T any](sm *Mapper, sources []T) ([]map[string]interface{}, error) {
+	if !isStructType(reflect.TypeOf((*T)(nil)).Elem()) {
+		return nil, ErrNotAStruct
+	}
+
+	return sm.ToMaps(sources)
+}
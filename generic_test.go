@@ -0,0 +1,128 @@
+package structmapper_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("Struct", func(t *testing.T) {
+		result, err := structmapper.Decode[mapperTestStructSimple](sm, map[string]interface{}{
+			"eff": "test",
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, mapperTestStructSimple{A: "test"}, result)
+	})
+
+	t.Run("Pointer", func(t *testing.T) {
+		result, err := structmapper.Decode[*mapperTestStructSimple](sm, map[string]interface{}{
+			"eff": "test",
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, &mapperTestStructSimple{A: "test"}, result)
+
+		result, err = structmapper.Decode[*mapperTestStructSimple](sm, map[string]interface{}{
+			"eff": 1.5,
+		})
+		require.Error(t, err)
+		require.Nil(t, result)
+	})
+
+	t.Run("NotAStruct", func(t *testing.T) {
+		result, err := structmapper.Decode[string](sm, map[string]interface{}{})
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+		require.Empty(t, result)
+
+		_, err = structmapper.Decode[**mapperTestStructSimple](sm, map[string]interface{}{})
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+	})
+}
+
+func TestDecodeSlice(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("OK", func(t *testing.T) {
+		results, err := structmapper.DecodeSlice[mapperTestStructSimple](sm, []map[string]interface{}{
+			{"eff": "test0"},
+			{"eff": "test1"},
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, []mapperTestStructSimple{{A: "test0"}, {A: "test1"}}, results)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		sources := []map[string]interface{}{
+			{"eff": "test0"},
+			{"eff": 1.5},
+			{"eff": "test2"},
+			nil,
+		}
+
+		results, err := structmapper.DecodeSlice[*mapperTestStructSimple](sm, sources)
+		require.Nil(t, results)
+		fieldErrors := requireFieldErrors(t, err, "[1].eff", "[3]")
+		require.True(t, errors.Is(fieldErrors["[3]"], structmapper.ErrMapIsNil))
+
+		sm, err := structmapper.NewMapper(structmapper.OptionFailFast(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		results, err = structmapper.DecodeSlice[*mapperTestStructSimple](sm, sources)
+		require.Nil(t, results)
+		requireFieldErrors(t, err, "[1].eff")
+	})
+
+	t.Run("NotAStruct", func(t *testing.T) {
+		results, err := structmapper.DecodeSlice[string](sm, []map[string]interface{}{{"eff": "test"}})
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+		require.Nil(t, results)
+
+		_, err = structmapper.DecodeSlice[**mapperTestStructSimple](sm, []map[string]interface{}{})
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+	})
+
+	t.Run("LimitError", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMaxLength(1))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		_, err = structmapper.DecodeSlice[mapperTestStructArraySlice](sm, []map[string]interface{}{
+			{"a": []interface{}{"a0"}},
+			{"a": []interface{}{"a0", "a1"}},
+		})
+		var le *structmapper.LimitError
+		require.True(t, errors.As(err, &le))
+		require.EqualValues(t, "[1].a", le.Path())
+	})
+}
+
+func TestEncodeSlice(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	results, err := structmapper.EncodeSlice(sm, []*mapperTestStructSimple{
+		{A: "test0"},
+		{A: "test1"},
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, []map[string]interface{}{{"eff": "test0"}, {"eff": "test1"}}, results)
+
+	results, err = structmapper.EncodeSlice(sm, []string{"test"})
+	require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+	require.Nil(t, results)
+
+	// Interface types are rejected as well, regardless of the values
+	results, err = structmapper.EncodeSlice(sm, []interface{}{&mapperTestStructSimple{A: "test"}})
+	require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+	require.Nil(t, results)
+}
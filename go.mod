@@ -1,12 +1,8 @@
 module github.com/anexia-it/go-structmapper
 
-go 1.17
+go 1.20
 
-require (
-	github.com/hashicorp/errwrap v0.0.0-20141028054710-7554cd9344ce
-	github.com/hashicorp/go-multierror v0.0.0-20160811015721-8c5f0ad93604
-	github.com/stretchr/testify v1.2.2
-)
+require github.com/stretchr/testify v1.2.2
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
@@ -0,0 +1,519 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// This file contains the JSON patch (RFC 6902) functionality of Mapper
+
+// JSONPatchOperation is a single operation of a JSON patch document, as defined by RFC 6902
+type JSONPatchOperation struct {
+	// Op is the operation: "add", "remove", "replace", "move", "copy" or "test"
+	Op string `json:"op"`
+	// Path is the JSON pointer to the target location, like "/servers/2/name"
+	Path string `json:"path"`
+	// From is the JSON pointer to the source location of "move" and "copy" operations
+	From string `json:"from,omitempty"`
+	// Value is the value of "add", "replace" and "test" operations.
+	// It is always encoded, as null is a valid value.
+	Value interface{} `json:"value"`
+}
+
+var (
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// parseJSONPointer splits the JSON pointer p into its unescaped reference tokens
+func parseJSONPointer(p string) ([]string, error) {
+	if p == "" {
+		// The whole document
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, ErrInvalidJSONPointer
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = jsonPointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// formatJSONPointer returns the JSON pointer representation of a path, like "/servers/2/tls/cert"
+func formatJSONPointer(path []pathElement) string {
+	var b strings.Builder
+	for _, e := range path {
+		b.WriteByte('/')
+		if e.index >= 0 {
+			b.WriteString(strconv.Itoa(e.index))
+			continue
+		}
+		b.WriteString(jsonPointerEscaper.Replace(e.name))
+	}
+	return b.String()
+}
+
+// jsonIndex parses token as an index of a slice of length n.
+// If end is set, "-" and n, designating the end of the slice, are accepted as well.
+func jsonIndex(token string, n int, end bool) (int, bool) {
+	if end && token == "-" {
+		return n, true
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > n || (i == n && !end) {
+		return 0, false
+	}
+	return i, true
+}
+
+// jsonMapKey returns the key of m which token refers to.
+// Keys are compared by their string representation, token itself is returned if there is no such key.
+func jsonMapKey(m map[interface{}]interface{}, token string) (interface{}, bool) {
+	if _, ok := m[token]; ok {
+		return token, true
+	}
+	for k := range m {
+		if fmt.Sprint(k) == token {
+			return k, true
+		}
+	}
+	return token, false
+}
+
+// jsonChild returns the child of the mapped value node which token refers to
+func jsonChild(node interface{}, token string) (interface{}, bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		value, ok := n[token]
+		return value, ok
+	case map[interface{}]interface{}:
+		k, ok := jsonMapKey(n, token)
+		return n[k], ok
+	case []interface{}:
+		i, ok := jsonIndex(token, len(n), false)
+		if !ok {
+			return nil, false
+		}
+		return n[i], true
+	}
+	return nil, false
+}
+
+// jsonGet returns the value which tokens refer to within the mapped value node
+func jsonGet(node interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		var ok bool
+		if node, ok = jsonChild(node, token); !ok {
+			return nil, ErrPathNotFound
+		}
+	}
+	return node, nil
+}
+
+// jsonUpdate calls fn with the parent of the value tokens refer to within the mapped value node, along with
+// the last token. The parent is replaced with the value returned by fn and the updated node is returned.
+func jsonUpdate(node interface{}, tokens []string,
+	fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+
+	child, ok := jsonChild(node, tokens[0])
+	if !ok {
+		return nil, ErrPathNotFound
+	}
+	child, err := jsonUpdate(child, tokens[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[tokens[0]] = child
+	case map[interface{}]interface{}:
+		k, _ := jsonMapKey(n, tokens[0])
+		n[k] = child
+	case []interface{}:
+		i, _ := jsonIndex(tokens[0], len(n), false)
+		n[i] = child
+	}
+	return node, nil
+}
+
+// jsonAdd adds value at the location tokens refer to within the mapped value node, replacing an existing
+// member of a map and inserting into a slice.
+// keyType is the key type of the Go map the parent has been mapped from, if known, which new keys are
+// converted to.
+func jsonAdd(node interface{}, tokens []string, value interface{}, keyType reflect.Type) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return jsonUpdate(node, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[token] = value
+			return p, nil
+		case map[interface{}]interface{}:
+			k, ok := jsonMapKey(p, token)
+			if !ok && keyType != nil {
+				k = parseScalar(keyType, token)
+			}
+			p[k] = value
+			return p, nil
+		case []interface{}:
+			i, ok := jsonIndex(token, len(p), true)
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+			s := make([]interface{}, 0, len(p)+1)
+			s = append(s, p[:i]...)
+			s = append(s, value)
+			return append(s, p[i:]...), nil
+		}
+		return nil, ErrPathNotFound
+	})
+}
+
+// jsonMapKeyType returns the key type of the map the value tokens refer to within a value of type t has been
+// mapped from, or nil if it is unknown
+func (sm *Mapper) jsonMapKeyType(t reflect.Type, tokens []string) reflect.Type {
+	for _, token := range tokens {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := sm.cachedFields(t)
+			i, ok := fields.byName[token]
+			if !ok {
+				return nil
+			}
+			t = fields.list[i].typ
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return nil
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Map {
+		return nil
+	}
+	return t.Key()
+}
+
+// jsonRemove removes the value tokens refer to within the mapped value node
+func jsonRemove(node interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		// The struct itself cannot be removed
+		return nil, ErrPathNotFound
+	}
+
+	return jsonUpdate(node, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[token]; ok {
+				delete(p, token)
+				return p, nil
+			}
+		case map[interface{}]interface{}:
+			if k, ok := jsonMapKey(p, token); ok {
+				delete(p, k)
+				return p, nil
+			}
+		case []interface{}:
+			if i, ok := jsonIndex(token, len(p), false); ok {
+				s := make([]interface{}, 0, len(p)-1)
+				s = append(s, p[:i]...)
+				return append(s, p[i+1:]...), nil
+			}
+		}
+		return nil, ErrPathNotFound
+	})
+}
+
+// copyJSONValue returns a deep copy of the mapped value v
+func copyJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, elem := range value {
+			m[k] = copyJSONValue(elem)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(value))
+		for k, elem := range value {
+			m[k] = copyJSONValue(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, elem := range value {
+			s[i] = copyJSONValue(elem)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual checks if the values a and b are equal as defined by the JSON patch test operation.
+// Numbers are compared by value regardless of their type and map keys by their string representation.
+func jsonEqual(a, b reflect.Value) bool {
+	for a.IsValid() && (a.Kind() == reflect.Interface || a.Kind() == reflect.Ptr) && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.IsValid() && (b.Kind() == reflect.Interface || b.Kind() == reflect.Ptr) && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	aNil := !a.IsValid() || ((a.Kind() == reflect.Interface || a.Kind() == reflect.Ptr) && a.IsNil())
+	bNil := !b.IsValid() || ((b.Kind() == reflect.Interface || b.Kind() == reflect.Ptr) && b.IsNil())
+	if aNil || bNil {
+		return aNil == bNil
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		aNumber, aOk := jsonNumber(a)
+		bNumber, bOk := jsonNumber(b)
+		return aOk && bOk && aNumber == bNumber
+	case reflect.String:
+		return b.Kind() == reflect.String && a.String() == b.String()
+	case reflect.Bool:
+		return b.Kind() == reflect.Bool && a.Bool() == b.Bool()
+	case reflect.Slice, reflect.Array:
+		if (b.Kind() != reflect.Slice && b.Kind() != reflect.Array) || a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !jsonEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if b.Kind() != reflect.Map || a.Len() != b.Len() {
+			return false
+		}
+		bEntries := make(map[string]reflect.Value, b.Len())
+		for _, k := range b.MapKeys() {
+			bEntries[fmt.Sprint(k.Interface())] = b.MapIndex(k)
+		}
+		for _, k := range a.MapKeys() {
+			bValue, ok := bEntries[fmt.Sprint(k.Interface())]
+			if !ok || !jsonEqual(a.MapIndex(k), bValue) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a.Type() == b.Type() && reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// jsonNumber returns the numeric value of v as float64
+func jsonNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// applyJSONPatchOperation applies op to the mapped value doc of the struct type t and returns the updated
+// value
+func (sm *Mapper) applyJSONPatchOperation(t reflect.Type, doc interface{},
+	op JSONPatchOperation) (interface{}, error) {
+	tokens, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyType reflect.Type
+	if len(tokens) > 0 {
+		keyType = sm.jsonMapKeyType(t, tokens[:len(tokens)-1])
+	}
+
+	switch op.Op {
+	case "add":
+		return jsonAdd(doc, tokens, copyJSONValue(op.Value), keyType)
+	case "remove":
+		return jsonRemove(doc, tokens)
+	case "replace":
+		if _, err := jsonGet(doc, tokens); err != nil {
+			return nil, err
+		}
+		return jsonAdd(doc, tokens, copyJSONValue(op.Value), keyType)
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			return jsonAdd(doc, tokens, copyJSONValue(value), keyType)
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			// A value cannot be moved into one of its children
+			return nil, ErrInvalidJSONPatchOp
+		}
+		if doc, err = jsonRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return jsonAdd(doc, tokens, value, keyType)
+	case "test":
+		value, err := jsonGet(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(reflect.ValueOf(value), reflect.ValueOf(op.Value)) {
+			return nil, ErrJSONPatchTestFailed
+		}
+		return doc, nil
+	}
+
+	return nil, ErrInvalidJSONPatchOp
+}
+
+func (sm *Mapper) applyJSONPatch(target interface{}, ops []JSONPatchOperation) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrNotAStructPointer
+	}
+
+	// Map empty fields as well, so they can be replaced and tested like all other fields
+	st := sm.newState()
+	st.keepEmpty = true
+	original, err := sm.mapRoot(st, target)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{} = copyJSONValue(original)
+	for i, op := range ops {
+		if doc, err = sm.applyJSONPatchOperation(v.Type().Elem(), doc, op); err != nil {
+			return fmt.Errorf("Operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return ErrInvalidMap
+	}
+
+	// Only unmap the fields which have been modified, resetting the fields which have been removed
+	for name, value := range original {
+		if newValue, ok := m[name]; !ok {
+			m[name] = nil
+		} else if reflect.DeepEqual(value, newValue) {
+			delete(m, name)
+		}
+	}
+
+	// Modified fields are replaced as a whole, merge mode is not enabled
+	return sm.unmapRoot(sm.newState(), m, target)
+}
+
+func (sm *Mapper) createJSONPatch(a, b interface{}) ([]JSONPatchOperation, error) {
+	var ops []JSONPatchOperation
+	var err error
+
+	st := sm.newState()
+	// Slice elements are always addressed by index
+	st.diff.byIndex = true
+	st.diff.onChange = func(kind ChangeKind, old, new interface{}) {
+		op := JSONPatchOperation{Path: formatJSONPointer(st.path)}
+		switch kind {
+		case ChangeAdded:
+			op.Op = "add"
+		case ChangeRemoved:
+			op.Op = "remove"
+		case ChangeModified:
+			op.Op = "replace"
+		}
+
+		if kind != ChangeRemoved {
+			// Convert nested maps to map[string]interface{}, so the value can be encoded as JSON
+			value, convErr := convertValueToStringKeys(reflect.ValueOf(new), sm.kindPolicy)
+			if convErr != nil {
+				convErr = st.newFieldError(st.pathString(), "", nil, reflect.TypeOf(new), convErr)
+				err = appendErrors(err, convErr)
+			}
+			op.Value = value
+		}
+		ops = append(ops, op)
+	}
+
+	if diffErr := sm.diffRoots(st, a, b); diffErr != nil {
+		return nil, diffErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	reverseElementRemovals(ops)
+	return ops, nil
+}
+
+// reverseElementRemovals reverses each run of operations removing elements of the same array.
+// Diff reports removed elements in ascending order, but each removal shifts the indexes of the elements
+// following it, so they have to be removed starting with the last one.
+func reverseElementRemovals(ops []JSONPatchOperation) {
+	for i := 0; i < len(ops); {
+		parent, ok := removedElementParent(ops[i])
+		j := i + 1
+		for ok && j < len(ops) {
+			if next, nextOk := removedElementParent(ops[j]); !nextOk || next != parent {
+				break
+			}
+			j++
+		}
+
+		for l, r := i, j-1; l < r; l, r = l+1, r-1 {
+			ops[l], ops[r] = ops[r], ops[l]
+		}
+		i = j
+	}
+}
+
+// removedElementParent returns the pointer to the parent of the value op removes, if op removes a value
+// referenced by an index
+func removedElementParent(op JSONPatchOperation) (string, bool) {
+	if op.Op != "remove" {
+		return "", false
+	}
+	i := strings.LastIndexByte(op.Path, '/')
+	if _, err := strconv.Atoi(op.Path[i+1:]); err != nil {
+		return "", false
+	}
+	return op.Path[:i], true
+}
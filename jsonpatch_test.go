@@ -0,0 +1,210 @@
+package structmapper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructJSONPatch struct {
+	Name    string                   `mapper:"name"`
+	Note    string                   `mapper:"note,omitempty"`
+	Servers []mapperTestStructServer `mapper:"servers"`
+	Labels  map[string]string        `mapper:"labels"`
+	Ports   map[int]string           `mapper:"ports"`
+	Primary *mapperTestStructServer  `mapper:"primary"`
+	Secret  string                   `mapper:"-"`
+}
+
+// newMapperTestStructJSONPatch returns a new patch target, so failed patches can be compared to an unmodified one
+func newMapperTestStructJSONPatch() *mapperTestStructJSONPatch {
+	return &mapperTestStructJSONPatch{
+		Name: "name",
+		Note: "note",
+		Servers: []mapperTestStructServer{
+			{Name: "web", Port: 80},
+			{Name: "db", Port: 5432},
+		},
+		Labels: map[string]string{
+			"env":      "dev",
+			"team/sub": "a",
+		},
+		Ports: map[int]string{
+			80: "http",
+		},
+		Primary: &mapperTestStructServer{Name: "primary", Port: 1},
+		Secret:  "secret",
+	}
+}
+
+func decodeJSONPatch(t *testing.T, patch string) []structmapper.JSONPatchOperation {
+	var ops []structmapper.JSONPatchOperation
+	require.NoError(t, json.Unmarshal([]byte(patch), &ops))
+	return ops
+}
+
+func TestMapper_ApplyJSONPatch(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("OK", func(t *testing.T) {
+		target := newMapperTestStructJSONPatch()
+		ops := decodeJSONPatch(t, `[
+			{"op": "test", "path": "/servers/1/port", "value": 5432},
+			{"op": "replace", "path": "/name", "value": "new"},
+			{"op": "remove", "path": "/note"},
+			{"op": "add", "path": "/servers/-", "value": {"name": "mail", "port": 25}},
+			{"op": "add", "path": "/servers/0", "value": {"name": "cache", "port": 6379}},
+			{"op": "remove", "path": "/servers/2"},
+			{"op": "copy", "from": "/labels/env", "path": "/labels/stage"},
+			{"op": "move", "from": "/labels/team~1sub", "path": "/labels/team"},
+			{"op": "replace", "path": "/ports/80", "value": "web"},
+			{"op": "replace", "path": "/primary/port", "value": 2},
+			{"op": "test", "path": "/labels", "value": {"env": "dev", "stage": "dev", "team": "a"}}
+		]`)
+
+		require.NoError(t, sm.ApplyJSONPatch(target, ops))
+		require.EqualValues(t, &mapperTestStructJSONPatch{
+			Name: "new",
+			Servers: []mapperTestStructServer{
+				{Name: "cache", Port: 6379},
+				{Name: "web", Port: 80},
+				{Name: "mail", Port: 25},
+			},
+			Labels: map[string]string{
+				"env":   "dev",
+				"stage": "dev",
+				"team":  "a",
+			},
+			Ports: map[int]string{
+				80: "web",
+			},
+			Primary: &mapperTestStructServer{Name: "primary", Port: 2},
+			Secret:  "secret",
+		}, target)
+	})
+
+	t.Run("EmptyField", func(t *testing.T) {
+		// Fields omitted if empty can be tested and replaced
+		target := &mapperTestStructJSONPatch{Name: "name"}
+		ops := decodeJSONPatch(t, `[
+			{"op": "test", "path": "/note", "value": ""},
+			{"op": "replace", "path": "/note", "value": "new"}
+		]`)
+
+		require.NoError(t, sm.ApplyJSONPatch(target, ops))
+		require.EqualValues(t, "new", target.Note)
+	})
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		target := newMapperTestStructJSONPatch()
+		ops := decodeJSONPatch(t, `[
+			{"op": "replace", "path": "/name", "value": "new"},
+			{"op": "replace", "path": "/servers/0/port", "value": true}
+		]`)
+
+		err := sm.ApplyJSONPatch(target, ops)
+		requireFieldErrors(t, err, "servers[0].port")
+		require.EqualValues(t, newMapperTestStructJSONPatch(), target)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for name, testCase := range map[string]struct {
+			patch    string
+			expected error
+		}{
+			"TestFailed":     {`[{"op": "test", "path": "/name", "value": "other"}]`, structmapper.ErrJSONPatchTestFailed},
+			"NotFound":       {`[{"op": "replace", "path": "/servers/2/name", "value": "x"}]`, structmapper.ErrPathNotFound},
+			"InvalidIndex":   {`[{"op": "add", "path": "/servers/01", "value": {}}]`, structmapper.ErrPathNotFound},
+			"RemoveRoot":     {`[{"op": "remove", "path": ""}]`, structmapper.ErrPathNotFound},
+			"InvalidPointer": {`[{"op": "remove", "path": "name"}]`, structmapper.ErrInvalidJSONPointer},
+			"InvalidOp":      {`[{"op": "invalid", "path": "/name"}]`, structmapper.ErrInvalidJSONPatchOp},
+			"MoveIntoChild": {
+				`[{"op": "move", "from": "/primary", "path": "/primary/name"}]`,
+				structmapper.ErrInvalidJSONPatchOp,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				target := newMapperTestStructJSONPatch()
+				ops := append(decodeJSONPatch(t, `[{"op": "replace", "path": "/name", "value": "new"}]`),
+					decodeJSONPatch(t, testCase.patch)...)
+
+				err := sm.ApplyJSONPatch(target, ops)
+				require.True(t, errors.Is(err, testCase.expected), "unexpected error: %v", err)
+				require.EqualValues(t, newMapperTestStructJSONPatch(), target)
+			})
+		}
+	})
+
+	t.Run("NotAStructPointer", func(t *testing.T) {
+		require.EqualError(t, sm.ApplyJSONPatch(mapperTestStructJSONPatch{}, nil),
+			structmapper.ErrNotAStructPointer.Error())
+	})
+}
+
+func TestMapper_CreateJSONPatch(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	a := newMapperTestStructJSONPatch()
+	b := newMapperTestStructJSONPatch()
+	b.Name = "new"
+	b.Note = ""
+	b.Servers = b.Servers[:1]
+	b.Labels["stage"] = "prod"
+	b.Ports[443] = "https"
+	b.Primary = nil
+
+	ops, err := sm.CreateJSONPatch(a, b)
+	require.NoError(t, err)
+	require.EqualValues(t, []structmapper.JSONPatchOperation{
+		{Op: "add", Path: "/labels/stage", Value: "prod"},
+		{Op: "replace", Path: "/name", Value: "new"},
+		{Op: "remove", Path: "/note"},
+		{Op: "add", Path: "/ports/443", Value: "https"},
+		{Op: "replace", Path: "/primary", Value: nil},
+		{Op: "remove", Path: "/servers/1"},
+	}, ops)
+
+	t.Run("Roundtrip", func(t *testing.T) {
+		// Apply the patch after encoding it as JSON
+		encoded, err := json.Marshal(ops)
+		require.NoError(t, err)
+
+		target := newMapperTestStructJSONPatch()
+		require.NoError(t, sm.ApplyJSONPatch(target, decodeJSONPatch(t, string(encoded))))
+		require.EqualValues(t, "new", target.Name)
+		require.EqualValues(t, "", target.Note)
+		require.EqualValues(t, b.Servers, target.Servers)
+		require.EqualValues(t, b.Labels, target.Labels)
+		require.Nil(t, target.Primary)
+	})
+
+	t.Run("RemovedElements", func(t *testing.T) {
+		// Elements are removed starting with the last one
+		b := newMapperTestStructJSONPatch()
+		b.Servers = b.Servers[:0]
+
+		ops, err := sm.CreateJSONPatch(a, b)
+		require.NoError(t, err)
+		require.EqualValues(t, []structmapper.JSONPatchOperation{
+			{Op: "remove", Path: "/servers/1"},
+			{Op: "remove", Path: "/servers/0"},
+		}, ops)
+
+		target := newMapperTestStructJSONPatch()
+		require.NoError(t, sm.ApplyJSONPatch(target, ops))
+		require.Len(t, target.Servers, 0)
+	})
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		ops, err := sm.CreateJSONPatch(a, *b)
+		require.EqualError(t, err, structmapper.ErrTypeMismatch.Error())
+		require.Nil(t, ops)
+	})
+}
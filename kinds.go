@@ -0,0 +1,52 @@
+package structmapper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// This file contains the handling of channel, function and unsafe.Pointer values
+
+// KindPolicy defines how Mapper handles channel, function and unsafe.Pointer values,
+// which cannot be represented in serialized data
+type KindPolicy int
+
+const (
+	// KindPolicySkip skips channel, function and unsafe.Pointer values.
+	// Struct fields and map entries holding such values are omitted, slice and array elements are set to nil.
+	// This is the default.
+	KindPolicySkip KindPolicy = iota
+	// KindPolicyError causes an error to be returned for channel, function and unsafe.Pointer values
+	KindPolicyError
+	// KindPolicyPassThrough passes channel, function and unsafe.Pointer values through as-is
+	KindPolicyPassThrough
+)
+
+// errSkipValue is returned internally for values which shall be skipped
+var errSkipValue = errors.New("Skip value")
+
+// isOpaqueKind checks if k is a channel, function or unsafe.Pointer kind
+func isOpaqueKind(k reflect.Kind) bool {
+	return k == reflect.Chan || k == reflect.Func || k == reflect.UnsafePointer
+}
+
+// checkOpaqueKind applies the given policy to values of type t.
+// errSkipValue is returned if the value shall be skipped, an error if it shall be rejected.
+func checkOpaqueKind(policy KindPolicy, t reflect.Type) error {
+	if !isOpaqueKind(t.Kind()) {
+		return nil
+	}
+
+	switch policy {
+	case KindPolicySkip:
+		return errSkipValue
+	case KindPolicyError:
+		return newErrorUnsupportedKind(t)
+	}
+	return nil
+}
+
+func newErrorUnsupportedKind(t reflect.Type) error {
+	return fmt.Errorf("%w: %s (kind: %s)", ErrUnsupportedKind, t.String(), t.Kind().String())
+}
@@ -0,0 +1,154 @@
+package structmapper_test
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructOpaque struct {
+	A string         `mapper:"a"`
+	C chan bool      `mapper:"c"`
+	F func()         `mapper:"f"`
+	P unsafe.Pointer `mapper:"p"`
+	S []interface{}  `mapper:"s"`
+}
+
+type mapperTestStructOpaqueMap struct {
+	Chans map[string]chan int `mapper:"chans"`
+}
+
+// requireUnsupportedKind checks if err wraps ErrUnsupportedKind
+func requireUnsupportedKind(t *testing.T, err error) {
+	require.Error(t, err)
+	require.True(t, errors.Is(err, structmapper.ErrUnsupportedKind), "ErrUnsupportedKind not found in %v", err)
+}
+
+func TestOptionKindPolicy(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicy(-1)))
+	require.Error(t, err)
+	require.Nil(t, sm)
+}
+
+func TestMapper_KindPolicy(t *testing.T) {
+	t.Run("Skip", func(t *testing.T) {
+		// Skipping is the default
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		expected := map[string]interface{}{
+			"a": "a",
+			"s": []interface{}{"s0", nil},
+		}
+
+		value := 1
+		source := &mapperTestStructOpaque{
+			A: "a",
+			C: make(chan bool),
+			F: func() {},
+			P: unsafe.Pointer(&value),
+			S: []interface{}{"s0", func() {}},
+		}
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+
+		// Existing values are left untouched
+		target := &mapperTestStructOpaque{
+			C: source.C,
+		}
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"a": "a",
+			"c": "ignored",
+			"f": "ignored",
+		}, target))
+		require.EqualValues(t, "a", target.A)
+		require.True(t, source.C == target.C)
+		require.Nil(t, target.F)
+
+		// Map entries are omitted, like with ToMap
+		mapTarget := &mapperTestStructOpaqueMap{}
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"chans": map[string]interface{}{
+				"a": make(chan int),
+			},
+		}, mapTarget))
+		require.NotNil(t, mapTarget.Chans)
+		require.Len(t, mapTarget.Chans, 0)
+
+		m, err = sm.ForceStringMapKeys(map[string]interface{}{
+			"a": "a",
+			"c": source.C,
+			"m": map[interface{}]interface{}{
+				1: source.F,
+				2: "b",
+			},
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{
+			"a": "a",
+			"m": map[string]interface{}{
+				"2": "b",
+			},
+		}, m)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		_, err = sm.ToMap(&mapperTestStructOpaque{C: make(chan bool)})
+		requireUnsupportedKind(t, err)
+
+		target := &mapperTestStructOpaque{}
+		requireUnsupportedKind(t, sm.ToStruct(map[string]interface{}{
+			"a": "a",
+			"c": make(chan bool),
+		}, target))
+		require.Empty(t, target.A)
+
+		m, err := sm.ForceStringMapKeys(map[string]interface{}{
+			"c": make(chan bool),
+		})
+		requireUnsupportedKind(t, err)
+		require.Nil(t, m)
+	})
+
+	t.Run("PassThrough", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyPassThrough))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		value := 1
+		source := &mapperTestStructOpaque{
+			A: "a",
+			C: make(chan bool),
+			F: func() {},
+			P: unsafe.Pointer(&value),
+			S: []interface{}{"s0", func() {}},
+		}
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.Len(t, m, 5)
+		require.True(t, source.C == m["c"].(chan bool))
+		require.NotNil(t, m["f"])
+		require.EqualValues(t, source.P, m["p"])
+
+		target := &mapperTestStructOpaque{}
+		require.NoError(t, sm.ToStruct(m, target))
+		require.True(t, source.C == target.C)
+		require.NotNil(t, target.F)
+		require.EqualValues(t, source.P, target.P)
+
+		converted, err := sm.ForceStringMapKeys(map[string]interface{}{
+			"c": source.C,
+		})
+		require.NoError(t, err)
+		require.True(t, source.C == converted["c"].(chan bool))
+	})
+}
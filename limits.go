@@ -0,0 +1,107 @@
+package structmapper
+
+import "fmt"
+
+// This file contains the limits enforced by Mapper
+
+// Limit designates a limit enforced by Mapper
+type Limit int
+
+const (
+	// LimitDepth designates the maximum nesting depth, set using OptionMaxDepth
+	LimitDepth Limit = iota + 1
+	// LimitLength designates the maximum length of a single slice, array or map,
+	// set using OptionMaxLength
+	LimitLength
+	// LimitElements designates the maximum total number of slice, array and map elements and struct
+	// fields, set using OptionMaxElements
+	LimitElements
+)
+
+// String returns the name of the limit
+func (l Limit) String() string {
+	switch l {
+	case LimitDepth:
+		return "depth"
+	case LimitLength:
+		return "length"
+	case LimitElements:
+		return "elements"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+var _ error = (*LimitError)(nil)
+
+// LimitError is an error that indicates that a limit has been exceeded.
+// Exceeding a limit aborts the whole ToMap or ToStruct call, which then returns the LimitError.
+type LimitError struct {
+	limit Limit
+	max   int
+	path  string
+}
+
+// Error returns the error string and causes LimitError to implement the error interface
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("Limit exceeded at '%s': %s is limited to %d", le.path, le.limit, le.max)
+}
+
+// Limit returns the limit which has been exceeded
+func (le *LimitError) Limit() Limit {
+	return le.limit
+}
+
+// Max returns the configured value of the limit
+func (le *LimitError) Max() int {
+	return le.max
+}
+
+// Path returns the path of the value at which the limit has been exceeded
+func (le *LimitError) Path() string {
+	return le.path
+}
+
+func newErrorLimit(limit Limit, max int, path string) error {
+	return &LimitError{
+		limit: limit,
+		max:   max,
+		path:  path,
+	}
+}
+
+// IsLimitError checks if the given error is a LimitError
+// and returns the LimitError along with a boolean that defines
+// if it is indeed a limit error.
+// The returned *LimitError may be nil, if the flag is false
+func IsLimitError(err error) (*LimitError, bool) {
+	le, ok := err.(*LimitError)
+	return le, ok
+}
+
+// checkDepth checks if the value at the current path exceeds the maximum depth
+func (sm *Mapper) checkDepth(st *state) error {
+	if sm.maxDepth > 0 && len(st.path) > sm.maxDepth {
+		return st.abort(newErrorLimit(LimitDepth, sm.maxDepth, st.pathString()))
+	}
+	return nil
+}
+
+// checkLength checks if a slice, array or map of the given length at the current path exceeds the
+// maximum length and adds its elements to the total number of elements
+func (sm *Mapper) checkLength(st *state, length int) error {
+	if sm.maxLength > 0 && length > sm.maxLength {
+		return st.abort(newErrorLimit(LimitLength, sm.maxLength, st.pathString()))
+	}
+
+	return sm.countElements(st, length)
+}
+
+// countElements adds the given number of elements at the current path to the total number of elements
+// and checks if it exceeds the maximum number of elements
+func (sm *Mapper) countElements(st *state, count int) error {
+	st.elements += count
+	if sm.maxElements > 0 && st.elements > sm.maxElements {
+		return st.abort(newErrorLimit(LimitElements, sm.maxElements, st.pathString()))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func requireLimitError(t *testing.T, err error, limit structmapper.Limit, max int, path string) {
+	require.Error(t, err)
+	le, ok := structmapper.IsLimitError(err)
+	require.EqualValues(t, true, ok, "returned error is not a *LimitError")
+	require.EqualValues(t, limit, le.Limit())
+	require.EqualValues(t, max, le.Max())
+	require.EqualValues(t, path, le.Path())
+}
+
+func TestOptionLimits(t *testing.T) {
+	t.Run("Negative", func(t *testing.T) {
+		for _, opt := range []structmapper.Option{
+			structmapper.OptionMaxDepth(-1),
+			structmapper.OptionMaxLength(-1),
+			structmapper.OptionMaxElements(-1),
+		} {
+			sm, err := structmapper.NewMapper(opt)
+			require.Error(t, err)
+			require.Nil(t, sm)
+		}
+	})
+}
+
+func TestMapper_Limits(t *testing.T) {
+	t.Run("Depth", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMaxDepth(3))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// B.B[0].eff has a depth of 4
+		m, err := sm.ToMap(&mapperTestStructNestedNestedStructSlice{
+			A: "test0",
+			B: mapperTestStructNestedStructSlice{
+				A: "test1",
+				B: []mapperTestStructSimple{
+					{
+						A: "test2",
+					},
+					{
+						A: "test3",
+					},
+				},
+			},
+		})
+		require.Nil(t, m)
+		requireLimitError(t, err, structmapper.LimitDepth, 3, "B.B[0].eff")
+
+		source := map[string]interface{}{
+			"A": "test0",
+			"B": map[string]interface{}{
+				"A": "test1",
+				"B": []interface{}{
+					map[string]interface{}{
+						"eff": "test2",
+					},
+				},
+			},
+		}
+
+		target := &mapperTestStructNestedNestedStructSlice{}
+		requireLimitError(t, sm.ToStruct(source, target), structmapper.LimitDepth, 3, "B.B[0].eff")
+		require.EqualValues(t, &mapperTestStructNestedNestedStructSlice{}, target)
+
+		// Without the nested slice the limit is not exceeded
+		delete(source["B"].(map[string]interface{}), "B")
+		require.NoError(t, sm.ToStruct(source, target))
+	})
+
+	t.Run("Length", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMaxLength(1))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		m, err := sm.ToMap(&mapperTestStructArraySlice{
+			A: []string{"a0", "a1"},
+		})
+		require.Nil(t, m)
+		requireLimitError(t, err, structmapper.LimitLength, 1, "a")
+
+		source := map[string]interface{}{
+			"a": make([]interface{}, 1000000),
+		}
+
+		target := &mapperTestStructArraySlice{}
+		requireLimitError(t, sm.ToStruct(source, target), structmapper.LimitLength, 1, "a")
+		require.Nil(t, target.A)
+
+		// The top-level map is limited as well
+		source = map[string]interface{}{
+			"a": []interface{}{"a0"},
+			"c": []interface{}{"c0"},
+		}
+		requireLimitError(t, sm.ToStruct(source, target), structmapper.LimitLength, 1, "")
+	})
+
+	t.Run("Elements", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMaxElements(6))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &mapperTestStructNestedNestedStructSlice{
+			A: "test0",
+			B: mapperTestStructNestedStructSlice{
+				A: "test1",
+				B: []mapperTestStructSimple{
+					{
+						A: "test2",
+					},
+					{
+						A: "test3",
+					},
+				},
+			},
+		}
+
+		// 2 fields, 2 nested fields, 2 slice elements and 1 field in the first element
+		m, err := sm.ToMap(source)
+		require.Nil(t, m)
+		requireLimitError(t, err, structmapper.LimitElements, 6, "B.B[0]")
+
+		sm, err = structmapper.NewMapper(structmapper.OptionMaxElements(8))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		m, err = sm.ToMap(source)
+		require.NoError(t, err)
+		require.NotNil(t, m)
+
+		target := &mapperTestStructNestedNestedStructSlice{}
+		require.NoError(t, sm.ToStruct(m, target))
+		require.EqualValues(t, source, target)
+
+		m["B"].(map[string]interface{})["B"] = append(m["B"].(map[string]interface{})["B"].([]interface{}),
+			map[string]interface{}{})
+		target = &mapperTestStructNestedNestedStructSlice{}
+		requireLimitError(t, sm.ToStruct(m, target), structmapper.LimitElements, 8, "B.B[1]")
+		require.EqualValues(t, &mapperTestStructNestedNestedStructSlice{}, target)
+	})
+}
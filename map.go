@@ -2,16 +2,19 @@ package structmapper
 
 import (
 	"encoding"
+	"fmt"
 	"reflect"
-	"unicode"
-
-	"github.com/hashicorp/go-multierror"
 )
 
 // This file contains the struct to map functionality of Mapper
 
-func (sm *Mapper) mapMap(v reflect.Value) (m map[interface{}]interface{}, err error) {
-	keys := v.MapKeys()
+func (sm *Mapper) mapMap(st *state, v reflect.Value) (m map[interface{}]interface{}, err error) {
+	if err = sm.checkLength(st, v.Len()); err != nil {
+		return
+	}
+
+	// Process the entries sorted by key, so errors are reported in a deterministic order
+	keys := sortedMapKeys(v)
 	m = make(map[interface{}]interface{}, len(keys))
 
 	for i := 0; i < len(keys); i++ {
@@ -19,10 +22,29 @@ func (sm *Mapper) mapMap(v reflect.Value) (m map[interface{}]interface{}, err er
 		keyI := keyV.Interface()
 		valueV := v.MapIndex(keyV)
 
-		valueI, mapErr := sm.mapValue(valueV.Interface(), valueV)
+		name := fmt.Sprint(keyI)
+		if !st.selected(pathElement{name: name, index: -1}) {
+			// Entry is not selected for mapping
+			continue
+		}
 
+		st.push(name)
+		leaveProjection := st.enterProjection(pathElement{name: name, index: -1})
+		valueI, mapErr := sm.mapValue(st, valueV.Interface(), valueV)
 		if mapErr != nil {
-			err = multierror.Append(err, mapErr)
+			mapErr = st.fieldError(mapErr, v.Type().Elem(), valueV.Interface())
+		}
+		leaveProjection()
+		st.pop()
+
+		if mapErr == errSkipValue {
+			// Entry is skipped due to the KindPolicy
+			continue
+		} else if mapErr != nil {
+			err = appendErrors(err, mapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
 		m[keyI] = valueI
@@ -31,16 +53,39 @@ func (sm *Mapper) mapMap(v reflect.Value) (m map[interface{}]interface{}, err er
 	return
 }
 
-func (sm *Mapper) mapSlice(v reflect.Value) (s []interface{}, err error) {
+func (sm *Mapper) mapSlice(st *state, v reflect.Value) (s []interface{}, err error) {
+	if err = sm.checkLength(st, v.Len()); err != nil {
+		return
+	}
+
 	s = make([]interface{}, 0, v.Len())
 
 	for i := 0; i < v.Len(); i++ {
 		valueV := v.Index(i)
 		valueI := valueV.Interface()
 
-		mappedValueI, mapErr := sm.mapValue(valueI, valueV)
+		if !st.selected(pathElement{index: i}) {
+			// Element is not selected for mapping
+			continue
+		}
+
+		st.pushIndex(i)
+		leaveProjection := st.enterProjection(pathElement{index: i})
+		mappedValueI, mapErr := sm.mapValue(st, valueI, valueV)
 		if mapErr != nil {
-			err = multierror.Append(err, mapErr)
+			mapErr = st.fieldError(mapErr, v.Type().Elem(), valueI)
+		}
+		leaveProjection()
+		st.pop()
+		if mapErr == errSkipValue {
+			// Element is skipped due to the KindPolicy
+			s = append(s, nil)
+			continue
+		} else if mapErr != nil {
+			err = appendErrors(err, mapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
 		s = append(s, mappedValueI)
@@ -49,7 +94,11 @@ func (sm *Mapper) mapSlice(v reflect.Value) (s []interface{}, err error) {
 	return
 }
 
-func (sm *Mapper) mapValue(i interface{}, v reflect.Value) (value interface{}, err error) {
+func (sm *Mapper) mapValue(st *state, i interface{}, v reflect.Value) (value interface{}, err error) {
+	if err = sm.checkDepth(st); err != nil {
+		return
+	}
+
 	// Check if the passed interface implements encoding.TextMarshaler, in which case we use the marshaler
 	// for generating the value
 	if marshaler, ok := i.(encoding.TextMarshaler); ok {
@@ -62,9 +111,37 @@ func (sm *Mapper) mapValue(i interface{}, v reflect.Value) (value interface{}, e
 		return
 	}
 
+	if i != nil {
+		// Apply the KindPolicy to channel, function and unsafe.Pointer values
+		if err = checkOpaqueKind(sm.kindPolicy, reflect.TypeOf(i)); err != nil {
+			return
+		}
+	}
+
 	// At this point it is safe to get rid of a possible pointer...
 	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		key := ptrKey{ptr: v.Pointer(), typ: v.Type()}
+		if sm.references {
+			if ref, ok := st.pointers.refs[key]; ok {
+				// Pointer has been mapped before: emit a reference marker
+				value = map[string]interface{}{RefKey: ref}
+				return
+			}
+			st.pointers.refs[key] = st.pathString()
+		} else {
+			if depth, ok := st.pointers.visiting[key]; ok {
+				// Pointer is already being mapped further up the path
+				err = newErrorCycle(st.pathString(), formatPath(st.path[:depth]))
+				return
+			}
+			st.pointers.visiting[key] = len(st.path)
+			defer delete(st.pointers.visiting, key)
+		}
 		v = v.Elem()
+
+		if err = checkOpaqueKind(sm.kindPolicy, v.Type()); err != nil {
+			return
+		}
 	} else if v.Kind() == reflect.Ptr {
 		// No-op for nil-pointers
 		return
@@ -74,11 +151,11 @@ func (sm *Mapper) mapValue(i interface{}, v reflect.Value) (value interface{}, e
 	switch v.Kind() {
 	case reflect.Struct:
 		// Handle struct
-		value, err = sm.mapStruct(v)
+		value, err = sm.mapStruct(st, v)
 	case reflect.Slice, reflect.Array:
-		value, err = sm.mapSlice(v)
+		value, err = sm.mapSlice(st, v)
 	case reflect.Map:
-		value, err = sm.mapMap(v)
+		value, err = sm.mapMap(st, v)
 	default:
 		// All other types are mapped as-is
 		value = i
@@ -87,71 +164,60 @@ func (sm *Mapper) mapValue(i interface{}, v reflect.Value) (value interface{}, e
 	return
 }
 
-func (sm *Mapper) mapAnonymousField(m map[string]interface{}, v reflect.Value) error {
-	if v.Kind() == reflect.Ptr && v.IsValid() && !v.IsNil() {
-		v = v.Elem()
-	}
-
-	// Call mapStruct on anoynmous field
-	mappedFields, err := sm.mapStruct(v)
-	if err != nil {
-		return err
-	}
-
-	// Merge onto map of struct containing anonymous field
-	for key, value := range mappedFields {
-		m[key] = value
+func (sm *Mapper) mapStruct(st *state, v reflect.Value) (m map[string]interface{}, err error) {
+	fields := sm.cachedFields(v.Type())
+	for _, tagErr := range fields.errs {
+		err = appendErrors(err, st.tagError(tagErr))
+		if st.done() {
+			return
+		}
 	}
-	return nil
-}
 
-func (sm *Mapper) mapStruct(v reflect.Value) (m map[string]interface{}, err error) {
-	if v.Kind() == reflect.Ptr && v.IsNil() {
-		return
+	if countErr := sm.countElements(st, len(fields.list)); countErr != nil {
+		return nil, countErr
 	}
 
-	t := v.Type()
-
 	// Create a new map that is pre-allocated with the number of fields v contains
-	m = make(map[string]interface{}, t.NumField())
+	m = make(map[string]interface{}, len(fields.list))
 
-	for i := 0; i < t.NumField(); i++ {
-		fieldD := t.Field(i)
-		fieldV := v.Field(i)
-
-		if fieldD.Anonymous {
-			if anonErr := sm.mapAnonymousField(m, fieldV); anonErr != nil {
-				err = multierror.Append(err, anonErr)
-			}
+	for _, f := range fields.list {
+		fieldV, ok := fieldByIndex(v, f.index)
+		if !ok {
+			// Field is promoted from a nil embedded struct pointer, ignore it
 			continue
 		}
-
-		fieldName := fieldD.Name
-
-		if !unicode.IsUpper([]rune(fieldName)[0]) {
-			// Ignore private fields
-			continue
-		}
-
-		fieldName, omitEmpty, tagErr := parseTagFromStructField(fieldD, sm.tagName)
-		if tagErr != nil {
-			// Parsing the tag failed, ignore the field and carry on
-			err = multierror.Append(err, tagErr)
+		if !st.selected(pathElement{name: f.name, index: -1}) {
+			// Field is not selected for mapping
 			continue
 		}
 
 		fieldI := fieldV.Interface()
 
-		if fieldName == "-" || omitEmpty && IsNilOrEmpty(fieldI, fieldV) {
-			// Tag defines that field shall be ignored or omitEmpty is set
-			// and the field is nil or empty
+		omitEmpty := f.omitEmpty && !st.keepEmpty
+		if omitEmpty && IsNilOrEmpty(fieldI, fieldV) {
+			// omitEmpty is set and the field is nil or empty
 			continue
 		} else if fieldI != nil {
 			// If field is non-nil, map it...
-			mappedFieldI, mappingErr := sm.mapValue(fieldI, fieldV)
+			st.push(f.name)
+			leave := st.enterField(f.goName)
+			leaveProjection := st.enterProjection(pathElement{name: f.name, index: -1})
+			mappedFieldI, mappingErr := sm.mapValue(st, fieldI, fieldV)
 			if mappingErr != nil {
+				mappingErr = st.fieldError(mappingErr, f.typ, fieldI)
+			}
+			leaveProjection()
+			leave()
+			st.pop()
+			if mappingErr == errSkipValue {
+				// Field is skipped due to the KindPolicy
+				continue
+			} else if mappingErr != nil {
 				// If mapping failed, add an error
-				err = multierror.Append(err, multierror.Prefix(mappingErr, fieldName+":"))
+				err = appendErrors(err, mappingErr)
+				if st.done() {
+					return
+				}
 				continue
 			}
 
@@ -163,21 +229,31 @@ func (sm *Mapper) mapStruct(v reflect.Value) (m map[string]interface{}, err erro
 			fieldI = mappedFieldI
 		}
 
-		m[fieldName] = fieldI
+		m[f.name] = fieldI
 	}
 
 	return
 }
 
 func (sm *Mapper) toMap(s interface{}) (map[string]interface{}, error) {
+	return sm.mapRoot(sm.newState(), s)
+}
+
+func (sm *Mapper) mapRoot(st *state, s interface{}) (map[string]interface{}, error) {
 	if s == nil {
 		// If the input struct is nil, return an empty map
 		return map[string]interface{}{}, nil
 	}
 
+	st.pointers = newPointerState()
+
 	// Verify that we are working on a struct...
 	v := reflect.ValueOf(s)
 	if v.Kind() == reflect.Ptr {
+		// Register the root pointer, so references to it can be detected
+		key := ptrKey{ptr: v.Pointer(), typ: v.Type()}
+		st.pointers.refs[key] = ""
+		st.pointers.visiting[key] = 0
 		v = v.Elem()
 	}
 
@@ -185,5 +261,10 @@ func (sm *Mapper) toMap(s interface{}) (map[string]interface{}, error) {
 		return nil, ErrNotAStruct
 	}
 
-	return sm.mapStruct(v)
+	m, err := sm.mapStruct(st, v)
+	if st.aborted() {
+		// Return the error which aborted the call as-is
+		return nil, st.abortErr
+	}
+	return m, err
 }
@@ -3,6 +3,7 @@ package structmapper_test
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/anexia-it/go-structmapper"
 	"github.com/stretchr/testify/require"
@@ -255,4 +256,137 @@ func TestMapper_ToMap(t *testing.T) {
 		require.NoError(t, err)
 		require.EqualValues(t, expected, m)
 	})
+
+	t.Run("AnonymousPromotion", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &MapperTestStructPromotionOuter{
+			MapperTestStructPromotionInnerA: MapperTestStructPromotionInnerA{
+				A: "inner a",
+				B: "inner a b",
+				C: "inner a c",
+				D: "inner a d",
+			},
+			MapperTestStructPromotionInnerB: &MapperTestStructPromotionInnerB{
+				B: "inner b b",
+				C: "inner b c",
+				D: "inner b d",
+			},
+			A: "outer",
+		}
+
+		// "a" is defined by the outer struct, which hides the embedded field.
+		// "b" and "d" are ambiguous and thus dropped.
+		// "C" is tagged in MapperTestStructPromotionInnerB, which beats the untagged field.
+		expected := map[string]interface{}{
+			"a": "outer",
+			"C": "inner b c",
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+
+	t.Run("TaggedAnonymous", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &MapperTestStructTaggedAnonymousOuter{
+			MapperTestStructAnonymousInner: MapperTestStructAnonymousInner{
+				A: "inner",
+			},
+			MapperTestStructPromotionInnerB: &MapperTestStructPromotionInnerB{
+				B: "ignored",
+			},
+			A: "outer",
+		}
+
+		expected := map[string]interface{}{
+			"meta": map[string]interface{}{
+				"a_inner": "inner",
+			},
+			"a_outer": "outer",
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+
+	t.Run("AnonymousNonStruct", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &MapperTestStructEmbeddedNonStruct{
+			MapperTestTags:     MapperTestTags{"t0", "t1"},
+			MapperTestStringer: mapperTestStringerValue("stringer"),
+			A:                  "a",
+		}
+
+		expected := map[string]interface{}{
+			"MapperTestTags":     []interface{}{"t0", "t1"},
+			"MapperTestStringer": mapperTestStringerValue("stringer"),
+			"a":                  "a",
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+
+	t.Run("AnonymousTextMarshaler", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &MapperTestStructEmbeddedTime{
+			Time: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+			A:    "a",
+		}
+
+		expected := map[string]interface{}{
+			"Time": "2021-06-01T12:00:00Z",
+			"a":    "a",
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+
+		// Nested, the struct is mapped as text through the promoted MarshalText method
+		m, err = sm.ToMap(&mapperTestStructEmbeddedTimeHolder{W: *source})
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{
+			"w": "2021-06-01T12:00:00Z",
+		}, m)
+	})
+
+	t.Run("AnonymousNilPtr", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := &MapperTestStructAnonymousPtrOuter{
+			A: "outer",
+		}
+
+		// The nil embedded pointer does not contribute any keys
+		expected := map[string]interface{}{
+			"a_outer": "outer",
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
 }
@@ -1,22 +1,202 @@
 package structmapper
 
-import "github.com/hashicorp/go-multierror"
+import "sync"
 
 // Mapper provides the mapping logic
 type Mapper struct {
-	tagName string
+	tagName    string
+	references bool
+
+	maxDepth    int
+	maxLength   int
+	maxElements int
+
+	kindPolicy KindPolicy
+
+	merge         bool
+	sliceStrategy SliceStrategy
+
+	errorOnUnknownKeys bool
+
+	failFast  bool
+	maxErrors int
+
+	workers int
+
+	cloneUnexported bool
+
+	// fieldCache caches the resolved fields per struct type
+	fieldCache sync.Map
 }
 
 // ToStruct takes a source map[string]interface{} and maps its values onto a target struct.
+//
+// A nil value resets the corresponding field, element or map entry to its zero value, like with Patch.
+// Pointers to embedded structs are only allocated if the source contains any of their keys.
+// Existing embedded struct pointers are reused.
+//
+// If an error is returned, the target and all values reachable from it are left untouched.
 func (mapper *Mapper) ToStruct(source map[string]interface{}, target interface{}) error {
 	return mapper.toStruct(source, target)
 }
 
+// ToStructWithMetadata works like ToStruct, but additionally returns which keys of source have been
+// used and which fields of target have been left unset.
+func (mapper *Mapper) ToStructWithMetadata(source map[string]interface{}, target interface{}) (Metadata, error) {
+	return mapper.toStructWithMetadata(source, target)
+}
+
+// Patch applies the values of patch onto the target struct, leaving all fields not contained in patch
+// untouched.
+// Nested maps are applied recursively to existing structs, pointers and maps, as in merge mode, while
+// an explicit nil resets a field to its zero value and removes a map entry.
+// The paths of all modified values are returned, like "servers[2].tls.cert". Values which are equal
+// to the existing values are not reported.
+func (mapper *Mapper) Patch(patch map[string]interface{}, target interface{}) ([]string, error) {
+	return mapper.patch(patch, target)
+}
+
+// ApplyMergePatch applies a JSON merge patch document, as defined by RFC 7386, to the target struct.
+//
+// The patch is applied like Patch applies its patch, using the same conversions as ToStruct: nested maps
+// are merged recursively and nil resets a field to its zero value and removes a map entry. Unlike with
+// Patch, slices and arrays are always replaced as a whole, regardless of the SliceStrategy.
+// If an error is returned, target is left untouched.
+func (mapper *Mapper) ApplyMergePatch(target interface{}, patch map[string]interface{}) error {
+	return mapper.applyMergePatch(target, patch)
+}
+
 // ToMap takes a source struct and maps its values onto a map[string]interface{}, which is then returned.
+//
+// Nested values implementing encoding.TextMarshaler are mapped to their text representation. This includes
+// structs embedding such a type, like time.Time, through the promoted MarshalText method, so their other
+// fields are not mapped unless the struct is the source itself. ToStruct handles encoding.TextUnmarshaler
+// the same way.
 func (mapper *Mapper) ToMap(source interface{}) (map[string]interface{}, error) {
 	return mapper.toMap(source)
 }
 
+// ToMapSelect works like ToMap, but only maps the values at the given paths, omitting all others.
+//
+// The paths use the mapped keys and are defined like for Get, like "owner.email". A path selects the value
+// it refers to along with all nested values. The wildcard "[*]", or "*" in JSON pointers, refers to all
+// elements of a slice or array and all entries of a map, like "items[*].id".
+// Values which are not selected are not processed at all. Slice and array elements which are not selected
+// are omitted, shifting the remaining ones.
+func (mapper *Mapper) ToMapSelect(source interface{}, paths ...string) (map[string]interface{}, error) {
+	return mapper.toMapProjection(source, paths, false)
+}
+
+// ToMapExclude works like ToMap, but omits the values at the given paths, which are defined like for
+// ToMapSelect.
+func (mapper *Mapper) ToMapExclude(source interface{}, paths ...string) (map[string]interface{}, error) {
+	return mapper.toMapProjection(source, paths, true)
+}
+
+// ToStructs takes a slice of source maps and maps each of them onto a new element of the slice target
+// points to, which is replaced if all source maps have been mapped successfully.
+// The elements of the target slice have to be structs or pointers to structs.
+//
+// Errors are reported with paths prefixed by the index of the source map, like "[2].servers[0].name".
+// The source maps are processed concurrently if enabled using OptionWorkers.
+func (mapper *Mapper) ToStructs(sources []map[string]interface{}, target interface{}) error {
+	return mapper.toStructs(sources, target)
+}
+
+// ToMaps takes a slice or array of source structs or pointers to structs and maps each of them onto a
+// map[string]interface{}, which are then returned in the same order.
+//
+// Errors are reported like ToStructs does.
+// The source structs are processed concurrently if enabled using OptionWorkers.
+func (mapper *Mapper) ToMaps(sources interface{}) ([]map[string]interface{}, error) {
+	return mapper.toMaps(sources)
+}
+
+// Copy copies the values of the fields of the source struct onto the fields of the target struct, which
+// target points to, matching the fields by the keys they are mapped to.
+// Nested structs, slices, arrays and maps are copied recursively, while all other values are converted
+// like ToStruct converts the values returned by ToMap, without building an intermediate map.
+//
+// Fields of the target for which the source has no field are left untouched. If an error is returned,
+// the target is left untouched entirely.
+func (mapper *Mapper) Copy(source interface{}, target interface{}) error {
+	return mapper.copy(source, target)
+}
+
+// Clone returns a deep copy of source, which has to be a struct or a pointer to a struct, of the same type.
+//
+// Pointers, slices and maps are copied recursively, while nil slices and maps stay nil and empty ones stay
+// empty. Pointers shared within source are shared within the copy as well, which also preserves cycles.
+// Channel, function and unsafe.Pointer values are copied as-is, regardless of the KindPolicy.
+// Fields tagged with "-" are copied as-is as well, so they can be used for sharing values like connection
+// pools between source and copy. Unexported fields are copied as-is, unless cloning them has been enabled
+// using OptionCloneUnexported.
+// Errors are reported with the paths of the Go struct fields, like "Servers[2].TLS".
+func (mapper *Mapper) Clone(source interface{}) (interface{}, error) {
+	return mapper.clone(source)
+}
+
+// Diff compares the structs a and b, which have to be of the same type, and returns their differences.
+//
+// Both structs are compared through the values they are mapped to by ToMap, so the returned changes hold
+// the mapped values and their paths use the mapped keys, like "servers[2].tls.cert".
+// Slices are compared by index, unless a key is defined in the tag of the field, like
+// `mapper:"servers,key=name"`: the elements are then matched by the value of the given key, regardless of
+// their order. Modified and added elements are reported at their index in b, removed ones at their index
+// in a. A nil pointer is compared like a struct without any fields.
+func (mapper *Mapper) Diff(a, b interface{}) ([]Change, error) {
+	return mapper.diff(a, b)
+}
+
+// ApplyJSONPatch applies the operations of a JSON patch document, as defined by RFC 6902, to the target
+// struct.
+//
+// The operations are applied to the values target is mapped to by ToMap, so their JSON pointers refer to
+// the mapped keys, like "/servers/2/name". Empty fields are included regardless of omitempty, so every
+// field of target can be replaced and tested. The modified fields are then mapped back onto target like
+// ToStruct does, including its type checks, while fields which have been removed are reset to their zero
+// value and all other fields are left untouched.
+// If an error is returned, target is left untouched.
+func (mapper *Mapper) ApplyJSONPatch(target interface{}, ops []JSONPatchOperation) error {
+	return mapper.applyJSONPatch(target, ops)
+}
+
+// CreateJSONPatch returns the JSON patch document, as defined by RFC 6902, which transforms the struct a
+// into b. Both structs have to be of the same type.
+//
+// The operations are created from the differences returned by Diff, but slice elements are always
+// addressed by index. Removed elements of a slice are removed starting with the last one, so the indexes
+// of the remaining ones stay valid. Nested maps of the values are converted like ForceStringMapKeys does.
+func (mapper *Mapper) CreateJSONPatch(a, b interface{}) ([]JSONPatchOperation, error) {
+	return mapper.createJSONPatch(a, b)
+}
+
+// Get returns the value at the given path within the source struct, as it is mapped by ToMap.
+//
+// The path uses the mapped keys and is either a JSON pointer, like "/servers/1/tls/cert", or a dotted path,
+// like "servers[1].tls.cert". An empty path refers to the source struct itself.
+// An error wrapping ErrPathNotFound is returned if the path does not refer to an existing value.
+func (mapper *Mapper) Get(source interface{}, path string) (interface{}, error) {
+	return mapper.get(source, path)
+}
+
+// Set sets the value at the given path within the struct target points to.
+//
+// The path is defined like for Get. The value is converted like ToStruct converts the values of its source
+// map, while nil pointers and maps along the path are allocated. Additionally, a string value is parsed if
+// the value at path is a number or a boolean, like "5432" for an int. Fields of interface types cannot be
+// set, like with ToStruct.
+// If an error is returned, target is left untouched.
+func (mapper *Mapper) Set(target interface{}, path string, value interface{}) error {
+	return mapper.set(target, path, value)
+}
+
+// ForceStringMapKeys works like the ForceStringMapKeys function, but applies the Mapper's KindPolicy
+// to channel, function and unsafe.Pointer values.
+func (mapper *Mapper) ForceStringMapKeys(in map[string]interface{}) (map[string]interface{}, error) {
+	return forceStringMapKeys(in, mapper.kindPolicy)
+}
+
 // NewMapper initializes a new mapper instance.
 // Optionally Mapper options may be passed to this function
 func NewMapper(options ...Option) (*Mapper, error) {
@@ -36,7 +216,7 @@ func NewMapper(options ...Option) (*Mapper, error) {
 	// This way the passed options override the default options
 	for _, opt := range options {
 		if optErr := opt(sm); optErr != nil {
-			err = multierror.Append(err, optErr)
+			err = appendErrors(err, optErr)
 		}
 	}
 
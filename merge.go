@@ -0,0 +1,95 @@
+package structmapper
+
+import "reflect"
+
+// This file contains the merge functionality of Mapper
+
+// SliceStrategy defines how ToStruct handles existing slices in merge mode
+type SliceStrategy int
+
+const (
+	// SliceReplace replaces existing slices. This is the default.
+	SliceReplace SliceStrategy = iota
+	// SliceAppend appends the elements to existing slices
+	SliceAppend
+	// SliceMergeByIndex merges the elements onto the existing elements with the same index,
+	// extending the slice if required
+	SliceMergeByIndex
+)
+
+// newSlice allocates the slice unmapSlice fills with length elements.
+// In merge mode, the existing elements of out are included according to the slice strategy and the
+// index of the first element to fill is returned.
+func (sm *Mapper) newSlice(st *state, out reflect.Value, t reflect.Type, length int) (reflect.Value, int) {
+	if !sm.mergesElements(st, out) {
+		return reflect.MakeSlice(t, length, length), 0
+	}
+
+	switch sm.sliceStrategy {
+	case SliceAppend:
+		s := reflect.MakeSlice(t, out.Len()+length, out.Len()+length)
+		reflect.Copy(s, out)
+		return s, out.Len()
+	case SliceMergeByIndex:
+		if out.Len() > length {
+			length = out.Len()
+		}
+		s := reflect.MakeSlice(t, length, length)
+		reflect.Copy(s, out)
+		return s, 0
+	}
+
+	return reflect.MakeSlice(t, length, length), 0
+}
+
+// mergesElements checks if the elements of the existing slice out are kept
+func (sm *Mapper) mergesElements(st *state, out reflect.Value) bool {
+	return st.merge.enabled && !st.merge.replaceLists && !out.IsNil() && sm.sliceStrategy != SliceReplace
+}
+
+// existingElement returns the element of out at index i, or the zero Value if out is too short
+func existingElement(out reflect.Value, i int) reflect.Value {
+	if i >= out.Len() {
+		return reflect.Value{}
+	}
+	return out.Index(i)
+}
+
+// mergePtr merges in onto the value the non-nil pointer out points to.
+// The value is only modified once the whole ToStruct call succeeded.
+func (sm *Mapper) mergePtr(st *state, in interface{}, out reflect.Value) error {
+	if sm.references {
+		path := st.pathString()
+		if _, ok := st.references.targets[path]; !ok {
+			st.references.targets[path] = out
+		}
+	}
+
+	existing := out.Elem()
+	targetV := reflect.New(existing.Type()).Elem()
+	targetV.Set(existing)
+
+	if err := sm.unmapValue(st, in, targetV, targetV.Type()); err != nil {
+		return err
+	}
+	st.trackChange(existing, targetV)
+
+	st.deferChange(func() {
+		existing.Set(targetV)
+	})
+	return nil
+}
+
+// mergeMap sets the entries of entries on the existing map out and removes the removed keys from it.
+// The map is only modified once the whole ToStruct call succeeded.
+func (sm *Mapper) mergeMap(st *state, out reflect.Value, entries reflect.Value, removed []reflect.Value) {
+	st.deferChange(func() {
+		iter := entries.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+		for _, key := range removed {
+			out.SetMapIndex(key, reflect.Value{})
+		}
+	})
+}
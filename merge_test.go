@@ -0,0 +1,212 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructMergeInner struct {
+	A string `mapper:"a"`
+	B int    `mapper:"b"`
+}
+
+type mapperTestStructMerge struct {
+	Name   string                        `mapper:"name"`
+	Inner  *mapperTestStructMergeInner   `mapper:"inner"`
+	Value  mapperTestStructMergeInner    `mapper:"value"`
+	Labels map[string]string             `mapper:"labels"`
+	Items  []mapperTestStructMergeInner  `mapper:"items"`
+	Array  [2]mapperTestStructMergeInner `mapper:"array"`
+}
+
+// newMapperTestStructMerge returns a new merge target, so modified ones can be compared to an unmodified one
+func newMapperTestStructMerge() *mapperTestStructMerge {
+	return &mapperTestStructMerge{
+		Name: "base",
+		Inner: &mapperTestStructMergeInner{
+			A: "inner",
+			B: 1,
+		},
+		Value: mapperTestStructMergeInner{
+			A: "value",
+			B: 2,
+		},
+		Labels: map[string]string{
+			"l0": "v0",
+			"l1": "v1",
+		},
+		Items: []mapperTestStructMergeInner{
+			{A: "i0", B: 3},
+			{A: "i1", B: 4},
+		},
+		Array: [2]mapperTestStructMergeInner{
+			{A: "a0", B: 5},
+			{A: "a1", B: 6},
+		},
+	}
+}
+
+func TestOptionSliceStrategy(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionSliceStrategy(structmapper.SliceStrategy(-1)))
+	require.Error(t, err)
+	require.Nil(t, sm)
+}
+
+func TestMapper_Merge(t *testing.T) {
+	overlay := map[string]interface{}{
+		"inner": map[string]interface{}{
+			"b": 10,
+		},
+		"value": map[string]interface{}{
+			"a": "value overlay",
+		},
+		"labels": map[string]interface{}{
+			"l1": "v1 overlay",
+			"l2": "v2",
+		},
+		"items": []interface{}{
+			map[string]interface{}{
+				"b": 30,
+			},
+		},
+		"array": []interface{}{
+			map[string]interface{}{
+				"a": "a0 overlay",
+			},
+		},
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		inner := target.Inner
+		require.NoError(t, sm.ToStruct(overlay, target))
+
+		// Without merge mode, values are replaced as a whole
+		require.EqualValues(t, "base", target.Name)
+		require.False(t, inner == target.Inner)
+		require.EqualValues(t, &mapperTestStructMergeInner{B: 10}, target.Inner)
+		require.EqualValues(t, mapperTestStructMergeInner{A: "value overlay"}, target.Value)
+		require.EqualValues(t, map[string]string{"l1": "v1 overlay", "l2": "v2"}, target.Labels)
+		require.EqualValues(t, []mapperTestStructMergeInner{{B: 30}}, target.Items)
+		require.EqualValues(t, [2]mapperTestStructMergeInner{{A: "a0 overlay"}}, target.Array)
+	})
+
+	t.Run("SliceReplace", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		inner := target.Inner
+		labels := target.Labels
+		require.NoError(t, sm.ToStruct(overlay, target))
+
+		require.EqualValues(t, "base", target.Name)
+		require.True(t, inner == target.Inner, "existing pointer has not been reused")
+		require.EqualValues(t, &mapperTestStructMergeInner{A: "inner", B: 10}, target.Inner)
+		require.EqualValues(t, mapperTestStructMergeInner{A: "value overlay", B: 2}, target.Value)
+		require.EqualValues(t, map[string]string{"l0": "v0", "l1": "v1 overlay", "l2": "v2"}, target.Labels)
+		require.EqualValues(t, labels, target.Labels)
+		labels["test"] = "test"
+		require.Len(t, target.Labels, 4, "existing map has not been reused")
+		require.EqualValues(t, []mapperTestStructMergeInner{{B: 30}}, target.Items)
+		require.EqualValues(t, [2]mapperTestStructMergeInner{{A: "a0 overlay", B: 5}, {A: "a1", B: 6}},
+			target.Array)
+	})
+
+	t.Run("SliceAppend", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true),
+			structmapper.OptionSliceStrategy(structmapper.SliceAppend))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{
+					"b": 30,
+				},
+			},
+		}, target))
+
+		require.EqualValues(t, []mapperTestStructMergeInner{{A: "i0", B: 3}, {A: "i1", B: 4}, {B: 30}},
+			target.Items)
+	})
+
+	t.Run("SliceMergeByIndex", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true),
+			structmapper.OptionSliceStrategy(structmapper.SliceMergeByIndex))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{
+					"b": 30,
+				},
+			},
+		}, target))
+
+		require.EqualValues(t, []mapperTestStructMergeInner{{A: "i0", B: 30}, {A: "i1", B: 4}}, target.Items)
+
+		// The slice is extended if required
+		overlay := map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{},
+				map[string]interface{}{},
+				map[string]interface{}{
+					"a": "i2",
+				},
+			},
+		}
+		require.NoError(t, sm.ToStruct(overlay, target))
+		require.EqualValues(t, []mapperTestStructMergeInner{{A: "i0", B: 30}, {A: "i1", B: 4}, {A: "i2"}},
+			target.Items)
+	})
+
+	t.Run("NilPointer", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// nil pointers and maps are allocated
+		target := &mapperTestStructMerge{}
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"inner": map[string]interface{}{
+				"b": 10,
+			},
+			"labels": map[string]interface{}{
+				"l1": "v1 overlay",
+				"l2": "v2",
+			},
+		}, target))
+		require.EqualValues(t, &mapperTestStructMergeInner{B: 10}, target.Inner)
+		require.EqualValues(t, map[string]string{"l1": "v1 overlay", "l2": "v2"}, target.Labels)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Nothing is modified if any value fails
+		target := newMapperTestStructMerge()
+		require.Error(t, sm.ToStruct(map[string]interface{}{
+			"name": 1.5,
+			"inner": map[string]interface{}{
+				"b": 10,
+			},
+			"labels": map[string]interface{}{
+				"l2": "v2",
+			},
+		}, target))
+		require.EqualValues(t, newMapperTestStructMerge(), target)
+	})
+}
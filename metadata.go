@@ -0,0 +1,53 @@
+package structmapper
+
+import "sort"
+
+// This file contains the metadata collection of Mapper
+
+// Metadata describes how the keys of a source map have been mapped by ToStructWithMetadata.
+// All keys and fields are given as their full paths, like "servers[2].tls.cert", sorted in ascending order.
+type Metadata struct {
+	// Keys holds the keys which have been mapped onto struct fields
+	Keys []string
+	// Unused holds the keys which do not correspond to any struct field, or which have been skipped due
+	// to the KindPolicy
+	Unused []string
+	// Unset holds the struct fields for which the source did not contain any key
+	Unset []string
+}
+
+// recordKey records the child name of the current path as mapped, if metadata is being collected
+func (st *state) recordKey(name string) {
+	if st.metadata != nil {
+		st.metadata.Keys = append(st.metadata.Keys, st.childPath(name))
+	}
+}
+
+// recordUnused records the child name of the current path as unused, if metadata is being collected
+func (st *state) recordUnused(name string) {
+	if st.metadata != nil {
+		st.metadata.Unused = append(st.metadata.Unused, st.childPath(name))
+	}
+}
+
+// recordUnset records the child name of the current path as unset, if metadata is being collected
+func (st *state) recordUnset(name string) {
+	if st.metadata != nil {
+		st.metadata.Unset = append(st.metadata.Unset, st.childPath(name))
+	}
+}
+
+func (sm *Mapper) toStructWithMetadata(m map[string]interface{}, s interface{}) (Metadata, error) {
+	st := sm.newState()
+	st.merge.enabled = sm.merge
+	st.metadata = &Metadata{}
+
+	if err := sm.unmapRoot(st, m, s); err != nil {
+		return Metadata{}, err
+	}
+
+	sort.Strings(st.metadata.Keys)
+	sort.Strings(st.metadata.Unused)
+	sort.Strings(st.metadata.Unset)
+	return *st.metadata, nil
+}
@@ -0,0 +1,78 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapper_ToStructWithMetadata(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("Nested", func(t *testing.T) {
+		target := &mapperTestStructNested{}
+		md, err := sm.ToStructWithMetadata(map[string]interface{}{
+			"a": "test",
+			"e": map[string]interface{}{
+				"eff":        "test",
+				"deprecated": true,
+			},
+			"timeuot": 1,
+		}, target)
+		require.NoError(t, err)
+		require.EqualValues(t, structmapper.Metadata{
+			Keys:   []string{"a", "e", "e.eff"},
+			Unused: []string{"e.deprecated", "timeuot"},
+			Unset:  []string{"b", "c", "dee"},
+		}, md)
+		require.EqualValues(t, &mapperTestStructNested{
+			A: "test",
+			E: &mapperTestStructSimple{
+				A: "test",
+			},
+		}, target)
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		target := &mapperTestStructNestedNestedStructSlice{}
+		md, err := sm.ToStructWithMetadata(map[string]interface{}{
+			"B": map[string]interface{}{
+				"B": []interface{}{
+					map[string]interface{}{},
+					map[string]interface{}{
+						"eff": "test",
+					},
+				},
+			},
+		}, target)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"B", "B.B", "B.B[1].eff"}, md.Keys)
+		require.Empty(t, md.Unused)
+		require.EqualValues(t, []string{"A", "B.A", "B.B[0].eff"}, md.Unset)
+	})
+
+	t.Run("KindPolicy", func(t *testing.T) {
+		// Fields skipped due to the KindPolicy are reported as unused
+		target := &mapperTestStructOpaque{}
+		md, err := sm.ToStructWithMetadata(map[string]interface{}{
+			"a": "a",
+			"c": "ignored",
+		}, target)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"a"}, md.Keys)
+		require.EqualValues(t, []string{"c"}, md.Unused)
+		require.EqualValues(t, []string{"f", "p", "s"}, md.Unset)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		target := &mapperTestStructNested{}
+		md, err := sm.ToStructWithMetadata(map[string]interface{}{
+			"a": 1.5,
+		}, target)
+		require.Error(t, err)
+		require.EqualValues(t, structmapper.Metadata{}, md)
+	})
+}
@@ -0,0 +1,167 @@
+package structmapper
+
+// This file contains the options of Mapper, apart from OptionTagName
+
+// OptionReferences enables or disables references.
+//
+// If references are enabled, ToMap emits a reference marker, a map holding the path of the pointer's
+// first occurrence under the RefKey key, for pointers which have been mapped before during the same call.
+// ToStruct resolves these markers back into shared pointers.
+//
+// If references are disabled, which is the default, ToMap returns a CycleError if it encounters a pointer
+// cycle.
+func OptionReferences(enabled bool) Option {
+	return func(m *Mapper) error {
+		m.references = enabled
+		return nil
+	}
+}
+
+// OptionMaxDepth sets the maximum nesting depth ToMap and ToStruct process.
+// The depth of a value is the number of elements of its path, so the fields of the passed struct have a
+// depth of 1. A maximum of 0, which is the default, disables the limit.
+func OptionMaxDepth(max int) Option {
+	return func(m *Mapper) error {
+		if max < 0 {
+			return ErrNegativeLimit
+		}
+
+		m.maxDepth = max
+		return nil
+	}
+}
+
+// OptionMaxLength sets the maximum length of a single slice, array or map ToMap and ToStruct process.
+// A maximum of 0, which is the default, disables the limit.
+func OptionMaxLength(max int) Option {
+	return func(m *Mapper) error {
+		if max < 0 {
+			return ErrNegativeLimit
+		}
+
+		m.maxLength = max
+		return nil
+	}
+}
+
+// OptionMaxElements sets the maximum total number of slice, array and map elements and struct fields a
+// single ToMap or ToStruct call processes.
+// A maximum of 0, which is the default, disables the limit.
+func OptionMaxElements(max int) Option {
+	return func(m *Mapper) error {
+		if max < 0 {
+			return ErrNegativeLimit
+		}
+
+		m.maxElements = max
+		return nil
+	}
+}
+
+// OptionKindPolicy sets the policy for handling channel, function and unsafe.Pointer values,
+// which is applied by ToMap, ToStruct and Mapper.ForceStringMapKeys.
+// The default is KindPolicySkip.
+func OptionKindPolicy(policy KindPolicy) Option {
+	return func(m *Mapper) error {
+		switch policy {
+		case KindPolicySkip, KindPolicyError, KindPolicyPassThrough:
+		default:
+			return ErrInvalidKindPolicy
+		}
+
+		m.kindPolicy = policy
+		return nil
+	}
+}
+
+// OptionMerge enables or disables merge mode.
+//
+// In merge mode, ToStruct merges the source onto the existing values of the target instead of replacing
+// them: existing non-nil pointers are followed, entries are added to or overwritten in existing maps
+// and existing slices are handled according to the SliceStrategy set using OptionSliceStrategy.
+// A nil value removes the entry from an existing map and does not add an entry to a new one.
+// Merge mode is disabled by default.
+func OptionMerge(enabled bool) Option {
+	return func(m *Mapper) error {
+		m.merge = enabled
+		return nil
+	}
+}
+
+// OptionSliceStrategy sets the strategy for handling existing slices in merge mode.
+// The default is SliceReplace.
+func OptionSliceStrategy(strategy SliceStrategy) Option {
+	return func(m *Mapper) error {
+		switch strategy {
+		case SliceReplace, SliceAppend, SliceMergeByIndex:
+		default:
+			return ErrInvalidSliceStrategy
+		}
+
+		m.sliceStrategy = strategy
+		return nil
+	}
+}
+
+// OptionErrorOnUnknownKeys enables or disables the rejection of unknown keys.
+//
+// If enabled, ToStruct returns an UnknownKeysError listing the paths of all keys of the source map and
+// its nested maps which do not correspond to any field of the target struct, including the fields
+// promoted from embedded structs. The target is not modified in this case.
+// Unknown keys are ignored by default.
+func OptionErrorOnUnknownKeys(enabled bool) Option {
+	return func(m *Mapper) error {
+		m.errorOnUnknownKeys = enabled
+		return nil
+	}
+}
+
+// OptionFailFast enables or disables fail-fast mode.
+//
+// In fail-fast mode, ToMap and ToStruct stop at the first error and return it, instead of processing the
+// remaining values and collecting all errors. Fail-fast mode is disabled by default.
+func OptionFailFast(enabled bool) Option {
+	return func(m *Mapper) error {
+		m.failFast = enabled
+		return nil
+	}
+}
+
+// OptionMaxErrors sets the maximum number of errors ToMap and ToStruct collect.
+// Once the maximum has been reached, the remaining values are not processed anymore.
+// A value of 0, which is the default, disables the limit.
+func OptionMaxErrors(max int) Option {
+	return func(m *Mapper) error {
+		if max < 0 {
+			return ErrNegativeLimit
+		}
+
+		m.maxErrors = max
+		return nil
+	}
+}
+
+// OptionWorkers sets the number of goroutines ToMaps and ToStructs use for processing the elements
+// concurrently. The result is identical to processing the elements sequentially, which is the default
+// and is also done if the number of workers is 0 or 1.
+func OptionWorkers(workers int) Option {
+	return func(m *Mapper) error {
+		if workers < 0 {
+			return ErrNegativeWorkers
+		}
+
+		m.workers = workers
+		return nil
+	}
+}
+
+// OptionCloneUnexported enables or disables cloning unexported struct fields.
+//
+// If enabled, Clone deep-copies unexported fields like exported ones. Otherwise, which is the default,
+// unexported fields are copied as-is, so values they point to are shared with the original.
+func OptionCloneUnexported(enabled bool) Option {
+	return func(m *Mapper) error {
+		m.cloneUnexported = enabled
+		return nil
+	}
+}
@@ -0,0 +1,21 @@
+package structmapper
+
+// This file contains the patch and JSON merge patch (RFC 7386) functionality of Mapper
+
+func (sm *Mapper) patch(patch map[string]interface{}, target interface{}) ([]string, error) {
+	st := sm.newState()
+	st.merge = mergeState{enabled: true, trackChanges: true}
+
+	if err := sm.unmapRoot(st, patch, target); err != nil {
+		return nil, err
+	}
+	return st.merge.changes, nil
+}
+
+func (sm *Mapper) applyMergePatch(target interface{}, patch map[string]interface{}) error {
+	st := sm.newState()
+	// Arrays are replaced as a whole, as defined by RFC 7386
+	st.merge = mergeState{enabled: true, replaceLists: true}
+
+	return sm.unmapRoot(st, patch, target)
+}
@@ -0,0 +1,220 @@
+package structmapper_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapper_Patch(t *testing.T) {
+	t.Run("Merge", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Patch always merges, regardless of OptionMerge
+		target := newMapperTestStructMerge()
+		inner := target.Inner
+		changes, err := sm.Patch(map[string]interface{}{
+			"inner": map[string]interface{}{
+				"b": 10,
+			},
+			"value": map[string]interface{}{
+				"a": "value overlay",
+			},
+			"labels": map[string]interface{}{
+				"l1": "v1 overlay",
+				"l2": "v2",
+			},
+			"items": []interface{}{
+				map[string]interface{}{
+					"b": 30,
+				},
+			},
+			"array": []interface{}{
+				map[string]interface{}{
+					"a": "a0 overlay",
+				},
+			},
+		}, target)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"inner.b", "value.a", "labels.l1", "labels.l2", "items", "array[0].a"},
+			changes)
+
+		require.EqualValues(t, "base", target.Name)
+		require.True(t, inner == target.Inner, "existing pointer has not been reused")
+		require.EqualValues(t, &mapperTestStructMergeInner{A: "inner", B: 10}, target.Inner)
+		require.EqualValues(t, mapperTestStructMergeInner{A: "value overlay", B: 2}, target.Value)
+		require.EqualValues(t, map[string]string{"l0": "v0", "l1": "v1 overlay", "l2": "v2"}, target.Labels)
+		require.EqualValues(t, []mapperTestStructMergeInner{{B: 30}}, target.Items)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		changes, err := sm.Patch(map[string]interface{}{
+			"name":  nil,
+			"inner": nil,
+			"value": map[string]interface{}{
+				"b": nil,
+			},
+			"labels": map[string]interface{}{
+				"l0":      nil,
+				"missing": nil,
+			},
+		}, target)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"name", "inner", "value.b", "labels.l0"}, changes)
+
+		require.Empty(t, target.Name)
+		require.Nil(t, target.Inner)
+		require.EqualValues(t, mapperTestStructMergeInner{A: "value"}, target.Value)
+		require.EqualValues(t, map[string]string{"l1": "v1"}, target.Labels)
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Values equal to the existing ones are not reported
+		target := newMapperTestStructMerge()
+		changes, err := sm.Patch(map[string]interface{}{
+			"name": "base",
+			"inner": map[string]interface{}{
+				"a": "inner",
+			},
+			"labels": map[string]interface{}{
+				"l0": "v0",
+			},
+			"items": []interface{}{
+				map[string]interface{}{"a": "i0", "b": 3},
+				map[string]interface{}{"a": "i1", "b": 4},
+			},
+		}, target)
+		require.NoError(t, err)
+		require.Empty(t, changes)
+		require.EqualValues(t, newMapperTestStructMerge(), target)
+	})
+
+	t.Run("NilPointer", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Allocated pointers are reported even if all nested values are zero values
+		target := &mapperTestStructMerge{}
+		changes, err := sm.Patch(map[string]interface{}{
+			"inner": map[string]interface{}{},
+		}, target)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"inner"}, changes)
+		require.EqualValues(t, &mapperTestStructMergeInner{}, target.Inner)
+	})
+
+	t.Run("SliceMergeByIndex", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionSliceStrategy(structmapper.SliceMergeByIndex))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		changes, err := sm.Patch(map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{},
+				map[string]interface{}{"b": 40},
+				map[string]interface{}{},
+			},
+		}, target)
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"items[1].b", "items[2]"}, changes)
+		require.EqualValues(t, []mapperTestStructMergeInner{{A: "i0", B: 3}, {A: "i1", B: 40}, {}}, target.Items)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		changes, err := sm.Patch(map[string]interface{}{
+			"name": 1.5,
+			"inner": map[string]interface{}{
+				"b": 10,
+			},
+			"labels": map[string]interface{}{
+				"l2": "v2",
+			},
+		}, target)
+		require.Error(t, err)
+		require.Nil(t, changes)
+		require.EqualValues(t, newMapperTestStructMerge(), target)
+	})
+}
+
+func TestMapper_ApplyMergePatch(t *testing.T) {
+	// Slices are replaced regardless of the SliceStrategy
+	sm, err := structmapper.NewMapper(structmapper.OptionSliceStrategy(structmapper.SliceAppend))
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	decode := func(t *testing.T, patch string) map[string]interface{} {
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(patch), &m))
+		return m
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		target := newMapperTestStructMerge()
+		inner := target.Inner
+		require.NoError(t, sm.ApplyMergePatch(target, decode(t, `{
+			"name": null,
+			"inner": {"b": 10},
+			"value": {"a": null},
+			"labels": {"l0": null, "l2": "v2"},
+			"items": [{"b": 30}],
+			"array": [{"a": "a0 overlay"}],
+			"unknown": "ignored"
+		}`)))
+
+		require.EqualValues(t, &mapperTestStructMerge{
+			Inner: &mapperTestStructMergeInner{A: "inner", B: 10},
+			Value: mapperTestStructMergeInner{B: 2},
+			Labels: map[string]string{
+				"l1": "v1",
+				"l2": "v2",
+			},
+			Items: []mapperTestStructMergeInner{{B: 30}},
+			Array: [2]mapperTestStructMergeInner{{A: "a0 overlay"}},
+		}, target)
+		require.True(t, inner == target.Inner, "existing pointer has not been reused")
+	})
+
+	t.Run("New", func(t *testing.T) {
+		// null does not add entries to new maps
+		target := &mapperTestStructMerge{}
+		require.NoError(t, sm.ApplyMergePatch(target, decode(t, `{
+			"inner": {"a": null, "b": 1},
+			"labels": {"l0": null, "l1": "v1"}
+		}`)))
+		require.EqualValues(t, &mapperTestStructMerge{
+			Inner:  &mapperTestStructMergeInner{B: 1},
+			Labels: map[string]string{"l1": "v1"},
+		}, target)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		target := newMapperTestStructMerge()
+		err := sm.ApplyMergePatch(target, decode(t, `{
+			"name": "new",
+			"labels": {"l0": null},
+			"inner": {"b": "invalid"}
+		}`))
+		requireFieldErrors(t, err, "inner.b")
+		require.EqualValues(t, newMapperTestStructMerge(), target)
+	})
+}
@@ -0,0 +1,386 @@
+package structmapper
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// This file contains the path-based access functionality of Mapper
+
+// parsePath parses a path, which is either a JSON pointer like "/servers/1/tls/cert" or a dotted path like
+// "servers[1].tls.cert".
+// The reference tokens of JSON pointers are returned as names, as they may refer to slice elements as well
+// as to map entries.
+// If wildcards is set, "[*]" is accepted in dotted paths and returned as the name "*".
+func parsePath(path string, wildcards bool) ([]pathElement, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if path[0] == '/' {
+		tokens, err := parseJSONPointer(path)
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]pathElement, len(tokens))
+		for i, token := range tokens {
+			elements[i] = pathElement{name: token, index: -1}
+		}
+		return elements, nil
+	}
+
+	var elements []pathElement
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name = part[:i]
+		}
+		if name == "" {
+			return nil, ErrInvalidPath
+		}
+		elements = append(elements, pathElement{name: name, index: -1})
+
+		// Parse the indexes following the name, like "[1][2]"
+		for rest := part[len(name):]; rest != ""; {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, ErrInvalidPath
+			}
+			digits := rest[1:end]
+			if wildcards && digits == projectionWildcard {
+				elements = append(elements, pathElement{name: projectionWildcard, index: -1})
+				rest = rest[end+1:]
+				continue
+			}
+			if digits == "" || strings.Trim(digits, "0123456789") != "" {
+				return nil, ErrInvalidPath
+			}
+			index, err := strconv.Atoi(digits)
+			if err != nil {
+				return nil, ErrInvalidPath
+			}
+			elements = append(elements, pathElement{index: index})
+			rest = rest[end+1:]
+		}
+	}
+	return elements, nil
+}
+
+// pathToken returns the name of the path element e, or its index as a string
+func pathToken(e pathElement) string {
+	if e.index >= 0 {
+		return strconv.Itoa(e.index)
+	}
+	return e.name
+}
+
+// pathIndex returns the index of a slice or array of length n the path element e refers to
+func pathIndex(e pathElement, n int) (int, bool) {
+	if e.index >= 0 {
+		return e.index, e.index < n
+	}
+	return jsonIndex(e.name, n, false)
+}
+
+// pathMapKey converts the path element e to a key of a map with the key type t.
+// Strings, numbers and booleans are parsed, all other keys are converted like ToStruct converts the values.
+func (sm *Mapper) pathMapKey(st *state, t reflect.Type, e pathElement) (reflect.Value, error) {
+	token := pathToken(e)
+	if k := reflect.ValueOf(parseScalar(t, token)); k.Type() == t {
+		return k, nil
+	}
+
+	k := reflect.New(t).Elem()
+	if err := sm.unmapValue(st, token, k, t); err != nil {
+		return reflect.Value{}, err
+	}
+	return k, nil
+}
+
+// pathChild returns the child of v the path element e refers to.
+// The returned flag is false if there is no such child.
+func (sm *Mapper) pathChild(st *state, v reflect.Value, e pathElement) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if mappedAsValue(v.Type()) {
+			return reflect.Value{}, false
+		}
+		fields := sm.cachedFields(v.Type())
+		i, ok := fields.byName[pathToken(e)]
+		if !ok {
+			return reflect.Value{}, false
+		}
+		child, ok := fieldByIndex(v, fields.list[i].index)
+		return child, ok
+	case reflect.Slice, reflect.Array:
+		i, ok := pathIndex(e, v.Len())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return v.Index(i), true
+	case reflect.Map:
+		k, err := sm.pathMapKey(st, v.Type().Key(), e)
+		if err != nil {
+			// No key of the map can be equal to the path element
+			return reflect.Value{}, false
+		}
+		child := v.MapIndex(k)
+		return child, child.IsValid()
+	}
+	return reflect.Value{}, false
+}
+
+// pathNotFound returns a FieldError for ErrPathNotFound at the current path, which does not refer to a
+// child of v
+func (st *state) pathNotFound(v reflect.Value) error {
+	var t reflect.Type
+	if v.IsValid() {
+		t = v.Type()
+	}
+	return st.fieldError(ErrPathNotFound, t, nil)
+}
+
+// mappedAsValue checks if the struct type t is mapped as a value, as it implements encoding.TextMarshaler
+func mappedAsValue(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// pushPath appends the path element e, which refers to a child of v, to the current path.
+// Elements referring to slice and array elements are appended as indexes.
+func (st *state) pushPath(e pathElement, v reflect.Value) {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		if i, err := strconv.Atoi(pathToken(e)); err == nil && i >= 0 {
+			st.pushIndex(i)
+			return
+		}
+	}
+	st.push(pathToken(e))
+}
+
+func (sm *Mapper) get(s interface{}, path string) (interface{}, error) {
+	elements, err := parsePath(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, ErrNotAStruct
+	}
+
+	st := sm.newState()
+	st.pointers = newPointerState()
+	for _, e := range elements {
+		for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+			v = v.Elem()
+		}
+
+		st.pushPath(e, v)
+		child, ok := sm.pathChild(st, v, e)
+		if !ok {
+			return nil, st.pathNotFound(v)
+		}
+		v = child
+	}
+
+	value, err := sm.mapValue(st, v.Interface(), v)
+	if err != nil {
+		if st.aborted() {
+			// Return the error which aborted the call as-is
+			return nil, st.abortErr
+		}
+		return nil, st.fieldError(err, v.Type(), v.Interface())
+	}
+	return value, nil
+}
+
+// parsePathValue converts the string s, which is about to be set on a value of type t, to a number or
+// boolean if t is a numeric or boolean type or a pointer to one, like "5432" for an int.
+// s is returned as-is for all other types, types implementing encoding.TextUnmarshaler and if the conversion
+// fails.
+func parsePathValue(t reflect.Type, s string) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return s
+	}
+	return parseScalar(t, s)
+}
+
+// setPath converts value like ToStruct does and sets the value the path elements refer to within v, which
+// has to be settable, to it.
+// Nil pointers and maps along the path are allocated. Values shared with v, like the values pointers point to,
+// slice elements and map entries, are modified by st.commit.
+func (sm *Mapper) setPath(st *state, v reflect.Value, elements []pathElement, value interface{}) error {
+	if len(elements) == 0 {
+		// Convert onto a new value, starting with a copy of the existing one in merge mode
+		target := reflect.New(v.Type()).Elem()
+		if st.merge.enabled {
+			target.Set(v)
+		}
+		if s, ok := value.(string); ok {
+			value = parsePathValue(v.Type(), s)
+		}
+		if err := sm.unmapValue(st, value, target, target.Type()); err == errSkipValue {
+			// Value is skipped due to the KindPolicy, leave it untouched like ToStruct does
+			return nil
+		} else if err != nil {
+			return st.fieldError(err, target.Type(), value)
+		}
+		v.Set(target)
+		return nil
+	}
+
+	e := elements[0]
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			// Set a copy of the value pointed to, which is applied once the whole call succeeded
+			child := reflect.New(v.Type().Elem()).Elem()
+			child.Set(v.Elem())
+			if err := sm.setPath(st, child, elements, value); err != nil {
+				return err
+			}
+			st.deferChange(func() {
+				v.Elem().Set(child)
+			})
+			return nil
+		}
+
+		// Allocate the pointer once the value has been set
+		child := reflect.New(v.Type().Elem())
+		if err := sm.setPath(st, child.Elem(), elements, value); err != nil {
+			return err
+		}
+		v.Set(child)
+		return nil
+	}
+
+	st.pushPath(e, v)
+	defer st.pop()
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if mappedAsValue(v.Type()) {
+			break
+		}
+		fields := sm.cachedFields(v.Type())
+		i, ok := fields.byName[pathToken(e)]
+		if !ok {
+			break
+		}
+		f := fields.list[i]
+		if f.typ.Kind() == reflect.Interface {
+			// Setting interfaces is unsupported, like with ToStruct
+			return st.newFieldError(st.pathString(), f.goName, f.typ, reflect.TypeOf(value), ErrFieldIsInterface)
+		}
+		if err := checkFieldByIndex(v, f.index); err != nil {
+			return st.newFieldError(st.pathString(), f.goName, f.typ, reflect.TypeOf(value), err)
+		}
+
+		// Set a copy of the field, which is applied once the value has been set
+		leave := st.enterField(f.goName)
+		defer leave()
+		child := reflect.New(f.typ).Elem()
+		if existing, ok := fieldByIndex(v, f.index); ok {
+			child.Set(existing)
+		}
+		if err := sm.setPath(st, child, elements[1:], value); err != nil {
+			return err
+		}
+		fieldByIndexAlloc(v, f.index).Set(child)
+		return nil
+	case reflect.Slice, reflect.Array:
+		i, ok := pathIndex(e, v.Len())
+		if !ok {
+			break
+		}
+		if v.Kind() == reflect.Array {
+			return sm.setPath(st, v.Index(i), elements[1:], value)
+		}
+
+		// Set a copy of the slice element, which is applied once the whole call succeeded
+		child := reflect.New(v.Type().Elem()).Elem()
+		child.Set(v.Index(i))
+		if err := sm.setPath(st, child, elements[1:], value); err != nil {
+			return err
+		}
+		st.deferChange(func() {
+			v.Index(i).Set(child)
+		})
+		return nil
+	case reflect.Map:
+		t := v.Type()
+		k, err := sm.pathMapKey(st, t.Key(), e)
+		if err == errSkipValue {
+			// Keys skipped due to the KindPolicy cannot be referred to
+			break
+		} else if err != nil {
+			return st.fieldError(err, t.Key(), pathToken(e))
+		}
+
+		// Set a copy of the entry, which is added to the map once the value has been set
+		child := reflect.New(t.Elem()).Elem()
+		if existing := v.MapIndex(k); existing.IsValid() {
+			child.Set(existing)
+		}
+		if err := sm.setPath(st, child, elements[1:], value); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+			v.SetMapIndex(k, child)
+			return nil
+		}
+		// Existing maps are modified once the whole call succeeded
+		st.deferChange(func() {
+			v.SetMapIndex(k, child)
+		})
+		return nil
+	}
+
+	return st.pathNotFound(v)
+}
+
+func (sm *Mapper) set(s interface{}, path string, value interface{}) error {
+	elements, err := parsePath(path, false)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrNotAStructPointer
+	}
+
+	st := sm.newState()
+	st.merge.enabled = sm.merge
+	if sm.references {
+		// Register the root pointer, so references to it can be resolved
+		st.references = newReferenceState()
+		st.references.targets[""] = v
+	}
+
+	// Set the value on a copy of the target, which is only applied if no error occurred
+	targetV := reflect.New(v.Elem().Type()).Elem()
+	targetV.Set(v.Elem())
+	if err := sm.setPath(st, targetV, elements, value); err != nil {
+		if st.aborted() {
+			// Return the error which aborted the call as-is
+			return st.abortErr
+		}
+		return err
+	}
+
+	if err := st.checkUnresolved(); err != nil {
+		return err
+	}
+	v.Elem().Set(targetV)
+	st.commit()
+	return nil
+}
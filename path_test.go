@@ -0,0 +1,178 @@
+package structmapper_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructPath struct {
+	Servers []mapperTestStructServer        `mapper:"servers"`
+	DB      mapperTestStructServer          `mapper:"db"`
+	Labels  map[string]string               `mapper:"labels"`
+	Ports   map[int]*mapperTestStructServer `mapper:"ports"`
+	Created time.Time                       `mapper:"created"`
+	Matrix  [][]int                         `mapper:"matrix"`
+	Any     interface{}                     `mapper:"any"`
+}
+
+// newMapperTestStructPath returns a new value to get and set paths on, so modified ones can be compared to it
+func newMapperTestStructPath() *mapperTestStructPath {
+	return &mapperTestStructPath{
+		Servers: []mapperTestStructServer{
+			{Name: "web"},
+			{Name: "db", TLS: &mapperTestStructTLS{Cert: "cert"}},
+		},
+		DB: mapperTestStructServer{Name: "postgres", Port: 5432},
+		Labels: map[string]string{
+			"team/sub": "a",
+		},
+		Created: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Matrix:  [][]int{{1, 2}, {3, 4}},
+	}
+}
+
+func TestMapper_Get(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	source := newMapperTestStructPath()
+
+	t.Run("OK", func(t *testing.T) {
+		for path, expected := range map[string]interface{}{
+			"servers[1].tls.cert": "cert",
+			"/servers/1/tls/cert": "cert",
+			"servers[0].name":     "web",
+			"db.port":             5432,
+			"/db":                 map[string]interface{}{"name": "postgres", "port": 5432},
+			"/labels/team~1sub":   "a",
+			"created":             "2021-01-02T03:04:05Z",
+			"matrix[1][0]":        3,
+			"/matrix/0":           []interface{}{1, 2},
+			"servers[0].tls":      nil,
+		} {
+			value, err := sm.Get(source, path)
+			require.NoError(t, err, path)
+			require.EqualValues(t, expected, value, path)
+		}
+
+		value, err := sm.Get(*source, "")
+		require.NoError(t, err)
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, m, value)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"servers[2].name":     "servers[2]",
+			"servers[0].tls.cert": "servers[0].tls.cert",
+			"db.missing":          "db.missing",
+			"/labels/missing":     "labels.missing",
+			"created.year":        "created.year",
+			"/ports/x":            "ports.x",
+		} {
+			_, err := sm.Get(source, path)
+			require.True(t, errors.Is(err, structmapper.ErrPathNotFound), path)
+			fieldErr, ok := structmapper.IsFieldError(err)
+			require.True(t, ok, path)
+			require.EqualValues(t, expected, fieldErr.Path(), path)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, path := range []string{"servers[a]", "servers[1", "servers..name", "[0]", "servers[-1]"} {
+			_, err := sm.Get(source, path)
+			require.EqualError(t, err, structmapper.ErrInvalidPath.Error(), path)
+		}
+
+		_, err := sm.Get("test", "name")
+		require.EqualError(t, err, structmapper.ErrNotAStruct.Error())
+	})
+}
+
+func TestMapper_Set(t *testing.T) {
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("OK", func(t *testing.T) {
+		target := newMapperTestStructPath()
+		// Strings are parsed if the target is a number or a boolean
+		require.NoError(t, sm.Set(target, "db.port", "5433"))
+		require.NoError(t, sm.Set(target, "/servers/0/tls/cert", "new"))
+		require.NoError(t, sm.Set(target, "servers[1].name", "database"))
+		require.NoError(t, sm.Set(target, "labels.env", "dev"))
+		require.NoError(t, sm.Set(target, "ports[443].port", int64(443)))
+		require.NoError(t, sm.Set(target, "created", "2022-01-02T03:04:05Z"))
+		require.NoError(t, sm.Set(target, "matrix[1]", []interface{}{5}))
+
+		expected := newMapperTestStructPath()
+		expected.DB.Port = 5433
+		expected.Servers[0].TLS = &mapperTestStructTLS{Cert: "new"}
+		expected.Servers[1].Name = "database"
+		expected.Labels["env"] = "dev"
+		expected.Ports = map[int]*mapperTestStructServer{443: {Port: 443}}
+		expected.Created = time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+		expected.Matrix[1] = []int{5}
+		require.EqualValues(t, expected, target)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"servers[2].name":   "servers[2]",
+			"servers[0].name.x": "servers[0].name.x",
+			"created.year":      "created.year",
+			"ports.x.port":      "ports.x",
+			"db.port":           "db.port",
+		} {
+			target := newMapperTestStructPath()
+			err := sm.Set(target, path, true)
+			fieldErr, ok := structmapper.IsFieldError(err)
+			require.True(t, ok, "%s: %v", path, err)
+			require.EqualValues(t, expected, fieldErr.Path(), path)
+			require.EqualValues(t, newMapperTestStructPath(), target, path)
+		}
+
+		err := sm.Set(&mapperTestStructPath{}, "servers[2].name", "name")
+		require.True(t, errors.Is(err, structmapper.ErrPathNotFound))
+
+		err = sm.Set(&mapperTestStructPath{}, "db.port", "x")
+		require.EqualError(t, err, "db.port: Type mismatch: int and string are incompatible")
+
+		// Interfaces cannot be set, like with ToStruct
+		err = sm.Set(&mapperTestStructPath{}, "any", 1)
+		require.True(t, errors.Is(err, structmapper.ErrFieldIsInterface))
+
+		require.EqualError(t, sm.Set(mapperTestStructPath{}, "db.port", 1),
+			structmapper.ErrNotAStructPointer.Error())
+	})
+
+	t.Run("References", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionReferences(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// References to the root are resolved
+		root := &mapperTestStructNode{
+			Name: "root",
+			Children: []*mapperTestStructNode{
+				{Name: "child"},
+			},
+		}
+		require.NoError(t, sm.Set(root, "children[0].parent", map[string]interface{}{structmapper.RefKey: ""}))
+		require.True(t, root == root.Children[0].Parent, "child does not point back to root")
+
+		// Nothing is modified if a reference cannot be resolved
+		for _, path := range []string{"ports[443]", "servers[1].tls", "db.tls"} {
+			target := newMapperTestStructPath()
+			err := sm.Set(target, path, map[string]interface{}{structmapper.RefKey: "nowhere"})
+			require.Error(t, err, path)
+			require.EqualValues(t, newMapperTestStructPath(), target, path)
+		}
+	})
+}
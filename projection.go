@@ -0,0 +1,124 @@
+package structmapper
+
+// This file contains the field projection functionality of Mapper
+
+// projectionWildcard is the path element matching all slice and array elements and map entries
+const projectionWildcard = "*"
+
+// projection is a tree of the paths selected or excluded by ToMapSelect and ToMapExclude
+type projection struct {
+	// all is set if a path ends here, so the value is selected or excluded as a whole
+	all bool
+	// children holds the projections of the children by their names or indexes, projectionWildcard
+	// for the wildcard
+	children map[string]*projection
+}
+
+// newProjection builds the projection of the given paths
+func newProjection(paths []string) (*projection, error) {
+	root := &projection{}
+	for _, path := range paths {
+		elements, err := parsePath(path, true)
+		if err != nil {
+			return nil, err
+		}
+
+		p := root
+		for _, e := range elements {
+			token := pathToken(e)
+			if p.children == nil {
+				p.children = make(map[string]*projection)
+			}
+			child, ok := p.children[token]
+			if !ok {
+				child = &projection{}
+				p.children[token] = child
+			}
+			p = child
+		}
+		p.all = true
+	}
+	return root, nil
+}
+
+// child returns the projection of the child with the given name or index, which is nil if no path refers
+// to it
+func (p *projection) child(token string) *projection {
+	named, wildcard := p.children[token], p.children[projectionWildcard]
+	if named == nil {
+		return wildcard
+	} else if wildcard == nil {
+		return named
+	}
+	return mergeProjections(named, wildcard)
+}
+
+// mergeProjections returns the union of the projections a and b
+func mergeProjections(a, b *projection) *projection {
+	merged := &projection{
+		all:      a.all || b.all,
+		children: make(map[string]*projection, len(a.children)+len(b.children)),
+	}
+	for token, child := range a.children {
+		merged.children[token] = child
+	}
+	for token, child := range b.children {
+		if existing, ok := merged.children[token]; ok {
+			child = mergeProjections(existing, child)
+		}
+		merged.children[token] = child
+	}
+	return merged
+}
+
+// childProjection returns the projection of the child e of the value currently being processed, which is nil
+// if the child is mapped as a whole.
+// false is returned if the child is not mapped at all.
+func (st *state) childProjection(e pathElement) (*projection, bool) {
+	if st.projection.paths == nil {
+		return nil, true
+	}
+
+	child := st.projection.paths.child(pathToken(e))
+	if st.projection.exclude {
+		return child, child == nil || !child.all
+	}
+	if child == nil {
+		return nil, false
+	}
+	if child.all {
+		return nil, true
+	}
+	return child, true
+}
+
+// selected checks if the child e of the value currently being processed is mapped
+func (st *state) selected(e pathElement) bool {
+	_, ok := st.childProjection(e)
+	return ok
+}
+
+// enterProjection descends into the projection of the child e of the value currently being processed and
+// returns a function restoring the previous one
+func (st *state) enterProjection(e pathElement) (leave func()) {
+	if st.projection.paths == nil {
+		return func() {}
+	}
+
+	p := st.projection.paths
+	st.projection.paths, _ = st.childProjection(e)
+	return func() {
+		st.projection.paths = p
+	}
+}
+
+func (sm *Mapper) toMapProjection(s interface{}, paths []string, exclude bool) (map[string]interface{}, error) {
+	p, err := newProjection(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	st := sm.newState()
+	st.projection = projectionState{paths: p, exclude: exclude}
+	return sm.mapRoot(st, s)
+}
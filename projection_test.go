@@ -0,0 +1,151 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructProjectionOwner struct {
+	Name  string `mapper:"name"`
+	Email string `mapper:"email"`
+}
+
+type mapperTestStructProjectionItem struct {
+	ID   int    `mapper:"id"`
+	Name string `mapper:"name"`
+}
+
+type mapperTestStructProjection struct {
+	ID      int                                       `mapper:"id"`
+	Name    string                                    `mapper:"name"`
+	Owner   *mapperTestStructProjectionOwner          `mapper:"owner"`
+	Items   []mapperTestStructProjectionItem          `mapper:"items"`
+	ByName  map[string]mapperTestStructProjectionItem `mapper:"by_name"`
+	Updates chan int                                  `mapper:"updates"`
+}
+
+func TestMapper_ToMapSelect(t *testing.T) {
+	// Channels would be rejected, so errors show which values have been processed
+	sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	source := &mapperTestStructProjection{
+		ID:   1,
+		Name: "name",
+		Owner: &mapperTestStructProjectionOwner{
+			Name:  "owner",
+			Email: "owner@example.com",
+		},
+		Items: []mapperTestStructProjectionItem{
+			{ID: 10, Name: "a"},
+			{ID: 11, Name: "b"},
+		},
+		ByName: map[string]mapperTestStructProjectionItem{
+			"x": {ID: 20, Name: "x"},
+		},
+		Updates: make(chan int),
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		m, err := sm.ToMapSelect(source, "id", "name", "owner.email", "items[*].id", "items[1]",
+			"/by_name/*/name")
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{
+			"id":   1,
+			"name": "name",
+			"owner": map[string]interface{}{
+				"email": "owner@example.com",
+			},
+			"items": []interface{}{
+				map[string]interface{}{"id": 10},
+				map[string]interface{}{"id": 11, "name": "b"},
+			},
+			"by_name": map[interface{}]interface{}{
+				"x": map[string]interface{}{"name": "x"},
+			},
+		}, m)
+	})
+
+	t.Run("Index", func(t *testing.T) {
+		// Elements which are not selected are omitted
+		m, err := sm.ToMapSelect(source, "items[1].name", "owner")
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{
+			"owner": map[string]interface{}{
+				"name":  "owner",
+				"email": "owner@example.com",
+			},
+			"items": []interface{}{
+				map[string]interface{}{"name": "b"},
+			},
+		}, m)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		m, err := sm.ToMapSelect(source)
+		require.NoError(t, err)
+		require.Empty(t, m)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := sm.ToMapSelect(source, "id", "updates")
+		requireFieldErrors(t, err, "updates")
+
+		_, err = sm.ToMapSelect(source, "items[x]")
+		require.EqualError(t, err, structmapper.ErrInvalidPath.Error())
+	})
+}
+
+func TestMapper_ToMapExclude(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionKindPolicy(structmapper.KindPolicyError))
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	source := &mapperTestStructProjection{
+		ID:   1,
+		Name: "name",
+		Owner: &mapperTestStructProjectionOwner{
+			Name:  "owner",
+			Email: "owner@example.com",
+		},
+		Items: []mapperTestStructProjectionItem{
+			{ID: 10, Name: "a"},
+			{ID: 11, Name: "b"},
+		},
+		ByName: map[string]mapperTestStructProjectionItem{
+			"x": {ID: 20, Name: "x"},
+		},
+		Updates: make(chan int),
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		m, err := sm.ToMapExclude(source, "updates", "owner.email", "items[*].name", "items[0]", "by_name")
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{
+			"id":   1,
+			"name": "name",
+			"owner": map[string]interface{}{
+				"name": "owner",
+			},
+			"items": []interface{}{
+				map[string]interface{}{"id": 11},
+			},
+		}, m)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		// Without any paths, everything is mapped
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+
+		expected, err := sm.ToMap(source)
+		require.NoError(t, err)
+
+		m, err := sm.ToMapExclude(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+}
@@ -0,0 +1,126 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// This file contains the pointer cycle and reference handling of Mapper
+
+// RefKey is the key of the reference marker ToMap emits for pointers which have already been
+// mapped during the same call, if references are enabled using OptionReferences.
+// The value of the marker is the path of the first occurrence of the pointer, the root being
+// referred to by an empty path.
+const RefKey = "$ref"
+
+var _ error = (*CycleError)(nil)
+
+// CycleError is an error that indicates that ToMap encountered a pointer cycle
+type CycleError struct {
+	path string
+	ref  string
+}
+
+// Error returns the error string and causes CycleError to implement the error interface
+func (ce *CycleError) Error() string {
+	return fmt.Sprintf("Cycle detected: pointer at '%s' refers back to '%s'", ce.path, ce.ref)
+}
+
+// Path returns the path of the pointer which closes the cycle
+func (ce *CycleError) Path() string {
+	return ce.path
+}
+
+// Ref returns the path the pointer refers back to
+func (ce *CycleError) Ref() string {
+	return ce.ref
+}
+
+func newErrorCycle(path, ref string) error {
+	return &CycleError{
+		path: path,
+		ref:  ref,
+	}
+}
+
+// IsCycleError checks if the given error is a CycleError
+// and returns the CycleError along with a boolean that defines
+// if it is indeed a cycle error.
+// The returned *CycleError may be nil, if the flag is false
+func IsCycleError(err error) (*CycleError, bool) {
+	ce, ok := err.(*CycleError)
+	return ce, ok
+}
+
+// referenceOf checks if in is a reference marker and returns the referenced path
+func referenceOf(in interface{}) (string, bool) {
+	var ref interface{}
+	switch m := in.(type) {
+	case map[string]interface{}:
+		if len(m) != 1 {
+			return "", false
+		}
+		ref = m[RefKey]
+	case map[interface{}]interface{}:
+		if len(m) != 1 {
+			return "", false
+		}
+		ref = m[RefKey]
+	}
+
+	path, ok := ref.(string)
+	return path, ok
+}
+
+// setReference points out to the pointer referenced by path.
+// If the referenced pointer has not been encountered yet, it is allocated and filled once it is.
+func (st *state) setReference(path string, out reflect.Value, t reflect.Type) error {
+	target, ok := st.references.targets[path]
+	if !ok {
+		target = reflect.New(t.Elem())
+		st.references.targets[path] = target
+		st.references.unresolved[path] = true
+	} else if target.Type() != t {
+		return fmt.Errorf("Reference to '%s': %s and %s are incompatible", path, t.String(),
+			target.Type().String())
+	}
+
+	out.Set(target)
+	return nil
+}
+
+// claimTarget returns the pointer to use for the current path, which either is a pointer allocated
+// by a previous reference to this path or a newly allocated one.
+func (st *state) claimTarget(t reflect.Type) (reflect.Value, error) {
+	path := st.pathString()
+	target, ok := st.references.targets[path]
+	if !ok {
+		target = reflect.New(t.Elem())
+		st.references.targets[path] = target
+		return target, nil
+	} else if !st.references.unresolved[path] {
+		// Path has already been claimed, which happens for pointers to pointers
+		return reflect.New(t.Elem()), nil
+	} else if target.Type() != t {
+		return reflect.Value{}, fmt.Errorf("Reference to '%s': %s and %s are incompatible", path,
+			target.Type().String(), t.String())
+	}
+
+	delete(st.references.unresolved, path)
+	return target, nil
+}
+
+// checkUnresolved returns an error if any references could not be resolved
+func (st *state) checkUnresolved() (err error) {
+	paths := make([]string, 0, len(st.references.unresolved))
+	for path := range st.references.unresolved {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		err = appendErrors(err, fmt.Errorf("Reference to '%s' could not be resolved", path))
+	}
+	return
+}
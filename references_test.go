@@ -0,0 +1,183 @@
+package structmapper_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+type mapperTestStructNode struct {
+	Name     string                  `mapper:"name"`
+	Parent   *mapperTestStructNode   `mapper:"parent"`
+	Children []*mapperTestStructNode `mapper:"children"`
+}
+
+type mapperTestStructShared struct {
+	A *mapperTestStructSimple `mapper:"a"`
+	B *mapperTestStructSimple `mapper:"b"`
+}
+
+// newMapperTestTree returns a tree in which each child points back to its parent, which cannot be written as a
+// single composite literal
+func newMapperTestTree() *mapperTestStructNode {
+	root := &mapperTestStructNode{
+		Name: "root",
+	}
+	child := &mapperTestStructNode{
+		Name:   "child",
+		Parent: root,
+	}
+	grandChild := &mapperTestStructNode{
+		Name:   "grandchild",
+		Parent: child,
+	}
+	child.Children = []*mapperTestStructNode{grandChild}
+	root.Children = []*mapperTestStructNode{child}
+	return root
+}
+
+func TestMapper_Cycle(t *testing.T) {
+	// Initialize Mapper without options
+	sm, err := structmapper.NewMapper()
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("Detected", func(t *testing.T) {
+		m, err := sm.ToMap(newMapperTestTree())
+		require.Error(t, err)
+		require.NotNil(t, m)
+
+		// The first cycle is closed by the child pointing back to the root
+		var cycleErr *structmapper.CycleError
+		require.True(t, errors.As(err, &cycleErr), "returned error does not contain a *CycleError")
+		require.EqualValues(t, "children[0].parent", cycleErr.Path())
+		require.EqualValues(t, "", cycleErr.Ref())
+	})
+
+	t.Run("SharedIsNoCycle", func(t *testing.T) {
+		simple := &mapperTestStructSimple{
+			A: "shared",
+		}
+		source := &mapperTestStructShared{
+			A: simple,
+			B: simple,
+		}
+
+		expected := map[string]interface{}{
+			"a": map[string]interface{}{
+				"eff": "shared",
+			},
+			"b": map[string]interface{}{
+				"eff": "shared",
+			},
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+}
+
+func TestMapper_References(t *testing.T) {
+	sm, err := structmapper.NewMapper(structmapper.OptionReferences(true))
+	require.NoError(t, err)
+	require.NotNil(t, sm)
+
+	t.Run("ToMap", func(t *testing.T) {
+		expected := map[string]interface{}{
+			"name":   "root",
+			"parent": nil,
+			"children": []interface{}{
+				map[string]interface{}{
+					"name":   "child",
+					"parent": map[string]interface{}{structmapper.RefKey: ""},
+					"children": []interface{}{
+						map[string]interface{}{
+							"name":     "grandchild",
+							"parent":   map[string]interface{}{structmapper.RefKey: "children[0]"},
+							"children": []interface{}{},
+						},
+					},
+				},
+			},
+		}
+
+		m, err := sm.ToMap(newMapperTestTree())
+		require.NoError(t, err)
+		require.EqualValues(t, expected, m)
+	})
+
+	t.Run("Roundtrip", func(t *testing.T) {
+		source := newMapperTestTree()
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+
+		target := &mapperTestStructNode{}
+		require.NoError(t, sm.ToStruct(m, target))
+
+		require.EqualValues(t, "root", target.Name)
+		require.Len(t, target.Children, 1)
+		child := target.Children[0]
+		require.EqualValues(t, "child", child.Name)
+		require.True(t, target == child.Parent, "child does not point back to root")
+		require.Len(t, child.Children, 1)
+		require.True(t, child == child.Children[0].Parent, "grandchild does not point back to child")
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		simple := &mapperTestStructSimple{
+			A: "shared",
+		}
+		source := &mapperTestStructShared{
+			A: simple,
+			B: simple,
+		}
+
+		m, err := sm.ToMap(source)
+		require.NoError(t, err)
+		require.EqualValues(t, map[string]interface{}{structmapper.RefKey: "a"}, m["b"])
+
+		target := &mapperTestStructShared{}
+		require.NoError(t, sm.ToStruct(m, target))
+		require.EqualValues(t, source, target)
+		require.True(t, target.A == target.B, "pointers are not shared")
+	})
+
+	t.Run("ForwardReference", func(t *testing.T) {
+		source := map[string]interface{}{
+			"a": map[string]interface{}{structmapper.RefKey: "b"},
+			"b": map[string]interface{}{
+				"eff": "shared",
+			},
+		}
+
+		target := &mapperTestStructShared{}
+		require.NoError(t, sm.ToStruct(source, target))
+		require.NotNil(t, target.A)
+		require.True(t, target.A == target.B, "pointers are not shared")
+		require.EqualValues(t, "shared", target.A.A)
+	})
+
+	t.Run("Unresolved", func(t *testing.T) {
+		source := map[string]interface{}{
+			"a": map[string]interface{}{structmapper.RefKey: "c"},
+		}
+
+		target := &mapperTestStructShared{}
+		require.Error(t, sm.ToStruct(source, target))
+		require.Nil(t, target.A)
+	})
+
+	t.Run("IncompatibleReference", func(t *testing.T) {
+		source := map[string]interface{}{
+			"a": map[string]interface{}{structmapper.RefKey: ""},
+		}
+
+		target := &mapperTestStructShared{}
+		require.Error(t, sm.ToStruct(source, target))
+		require.Nil(t, target.A)
+	})
+}
@@ -0,0 +1,308 @@
+package structmapper
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// This file contains the per-call state of Mapper
+
+// pathElement is a single element of the path to a value
+type pathElement struct {
+	// name is the name of a struct field or the key of a map entry
+	name string
+	// index is the index of a slice or array element, or -1 if name is used
+	index int
+}
+
+// formatPath returns the string representation of a path, like "servers[2].tls.cert"
+func formatPath(path []pathElement) string {
+	var b strings.Builder
+	for _, e := range path {
+		if e.index >= 0 {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(e.index))
+			b.WriteByte(']')
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(e.name)
+	}
+	return b.String()
+}
+
+// joinPath returns the string representation of path, relative to the path prefix
+func joinPath(prefix, path string) string {
+	if prefix == "" || path == "" {
+		return prefix + path
+	}
+	if path[0] == '[' {
+		return prefix + path
+	}
+	return prefix + "." + path
+}
+
+// ptrKey identifies a pointer.
+// The type is required as a pointer to a struct and a pointer to its first field share the same address.
+type ptrKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// state holds the state of a single call of one of the entry points of Mapper, like ToMap, ToStruct or Diff.
+//
+// Besides the path and the error handling, which are used by all calls, the state is grouped per feature.
+// newState only initializes the former, each entry point sets up the groups it uses.
+type state struct {
+	// path is the path to the value currently being processed
+	path []pathElement
+	// field is the name of the Go struct field the value currently being processed belongs to
+	field string
+
+	// elements is the total number of elements processed so far
+	elements int
+	// abortErr holds the error which aborted the call, if any
+	abortErr error
+	// errCount is the number of errors collected so far
+	errCount int
+	// maxErrors is the number of errors after which no further values are processed, 0 if unlimited
+	maxErrors int
+
+	// deferred holds the modifications of existing values, which are applied once the whole call
+	// succeeded (ToStruct, Copy, Set)
+	deferred []func()
+
+	// pointers tracks the pointers mapped so far (ToMap, Copy, Get)
+	pointers pointerState
+	// references tracks the pointers allocated for reference markers (ToStruct, with references enabled)
+	references referenceState
+	// merge holds the state of merge mode (ToStruct, Patch, ApplyMergePatch)
+	merge mergeState
+	// diff holds the state of comparing mapped values (Diff, CreateJSONPatch)
+	diff diffState
+	// projection holds the state of mapping selected values only (ToMapSelect, ToMapExclude)
+	projection projectionState
+
+	// unknownKeys holds the paths of the source keys which do not correspond to any field (ToStruct,
+	// with unknown keys being rejected)
+	unknownKeys []string
+	// metadata holds the metadata collected so far (ToStructWithMetadata)
+	metadata *Metadata
+	// clones holds the clones of all pointers cloned so far (Clone)
+	clones map[ptrKey]reflect.Value
+	// keepEmpty defines if empty values are mapped even if omitempty is set (ApplyJSONPatch)
+	keepEmpty bool
+}
+
+// pointerState tracks the pointers mapped so far
+type pointerState struct {
+	// visiting holds the pointers on the current path, along with the path length at which they
+	// have been encountered
+	visiting map[ptrKey]int
+	// refs holds the paths of all pointers mapped so far (with references enabled)
+	refs map[ptrKey]string
+}
+
+// newPointerState initializes the tracking of mapped pointers
+func newPointerState() pointerState {
+	return pointerState{
+		visiting: make(map[ptrKey]int),
+		refs:     make(map[ptrKey]string),
+	}
+}
+
+// referenceState tracks the pointers allocated for reference markers
+type referenceState struct {
+	// targets holds the pointers allocated per path
+	targets map[string]reflect.Value
+	// unresolved holds the paths of pointers which have been referenced, but not yet been encountered
+	unresolved map[string]bool
+}
+
+// newReferenceState initializes the tracking of pointers allocated for reference markers
+func newReferenceState() referenceState {
+	return referenceState{
+		targets:    make(map[string]reflect.Value),
+		unresolved: make(map[string]bool),
+	}
+}
+
+// mergeState holds the state of merge mode
+type mergeState struct {
+	// enabled defines if existing values are merged
+	enabled bool
+	// replaceLists defines if slices and arrays are replaced as a whole, regardless of the SliceStrategy
+	// (ApplyMergePatch)
+	replaceLists bool
+	// trackChanges defines if the paths of modified values are recorded in changes (Patch)
+	trackChanges bool
+	// changes holds the paths of the values modified so far (Patch)
+	changes []string
+}
+
+// diffState holds the state of comparing mapped values
+type diffState struct {
+	// onChange is called for each difference found, at the path of the differing value
+	onChange func(kind ChangeKind, old, new interface{})
+	// byIndex defines if slices are compared by index, even if a key has been defined (CreateJSONPatch)
+	byIndex bool
+}
+
+// projectionState holds the state of mapping selected values only
+type projectionState struct {
+	// paths holds the paths selected or excluded below the value currently being processed, nil if all
+	// values are mapped
+	paths *projection
+	// exclude defines if paths are excluded instead of selected
+	exclude bool
+}
+
+// newState initializes the state of a single call
+func (sm *Mapper) newState() *state {
+	st := &state{
+		maxErrors: sm.maxErrors,
+	}
+	if sm.failFast {
+		st.maxErrors = 1
+	}
+	return st
+}
+
+// push appends the name of a struct field or map key to the current path
+func (st *state) push(name string) {
+	st.path = append(st.path, pathElement{name: name, index: -1})
+}
+
+// pushIndex appends a slice or array index to the current path
+func (st *state) pushIndex(index int) {
+	st.path = append(st.path, pathElement{index: index})
+}
+
+// pop removes the last element from the current path
+func (st *state) pop() {
+	st.path = st.path[:len(st.path)-1]
+}
+
+// abort marks the call as aborted by err and returns err
+func (st *state) abort(err error) error {
+	st.abortErr = err
+	return err
+}
+
+// aborted checks if the call has been aborted
+func (st *state) aborted() bool {
+	return st.abortErr != nil
+}
+
+// done checks if no further values shall be processed, as the call has been aborted or the maximum
+// number of errors has been collected
+func (st *state) done() bool {
+	return st.aborted() || (st.maxErrors > 0 && st.errCount >= st.maxErrors)
+}
+
+// deferChange registers a modification of an existing value, which is applied by commit
+func (st *state) deferChange(fn func()) {
+	st.deferred = append(st.deferred, fn)
+}
+
+// commit applies all deferred modifications in the order they have been registered
+func (st *state) commit() {
+	for _, fn := range st.deferred {
+		fn()
+	}
+	st.deferred = nil
+}
+
+// trackChange records the current path as changed if old and new differ.
+// An invalid old or new value denotes a value which does not exist.
+// Values containing changes which have already been recorded are not recorded themselves, so only
+// the most specific paths are reported.
+func (st *state) trackChange(old, new reflect.Value) {
+	if !st.merge.trackChanges {
+		return
+	}
+
+	if old.IsValid() && new.IsValid() {
+		if reflect.DeepEqual(old.Interface(), new.Interface()) {
+			return
+		}
+	} else if old.IsValid() == new.IsValid() {
+		return
+	}
+
+	path := st.pathString()
+	if n := len(st.merge.changes); n > 0 {
+		// Changes of children are always recorded before the change of their parent
+		last := st.merge.changes[n-1]
+		if last == path || (strings.HasPrefix(last, path) &&
+			(path == "" || last[len(path)] == '.' || last[len(path)] == '[')) {
+			return
+		}
+	}
+	st.merge.changes = append(st.merge.changes, path)
+}
+
+// pauseChanges stops recording changes until the returned function is called.
+// This is used while unmapping onto new values, as the changes are reported for the new value itself.
+func (st *state) pauseChanges() (resume func()) {
+	trackChanges := st.merge.trackChanges
+	st.merge.trackChanges = false
+	return func() {
+		st.merge.trackChanges = trackChanges
+	}
+}
+
+// enterField sets the Go struct field the values processed next belong to and returns a function
+// restoring the previous one
+func (st *state) enterField(name string) (leave func()) {
+	field := st.field
+	st.field = name
+	return func() {
+		st.field = field
+	}
+}
+
+// fieldError wraps err, which occurred while processing the value at the current path, into a
+// FieldError. typ is the Go type of the value and in the received value.
+// Errors which already carry a path are returned as-is.
+func (st *state) fieldError(err error, typ reflect.Type, in interface{}) error {
+	switch err.(type) {
+	case *FieldError, *MultiError, *LimitError, *UnknownKeysError:
+		return err
+	case *CycleError:
+		st.errCount++
+		return err
+	}
+	if err == errSkipValue {
+		return err
+	}
+	return st.newFieldError(st.pathString(), st.field, typ, reflect.TypeOf(in), err)
+}
+
+// tagError returns the error which occurred while parsing the tag of a field of the struct at the current
+// path as a FieldError
+func (st *state) tagError(tagErr tagError) error {
+	return st.newFieldError(st.childPath(tagErr.field), tagErr.field, tagErr.typ, nil, tagErr.err)
+}
+
+// newFieldError returns a new FieldError and counts it towards the maximum number of errors
+func (st *state) newFieldError(path, field string, typ, valueType reflect.Type, err error) error {
+	st.errCount++
+	return newErrorField(path, field, typ, valueType, err)
+}
+
+// childPath returns the string representation of the path of the child name of the current path
+func (st *state) childPath(name string) string {
+	st.push(name)
+	defer st.pop()
+	return st.pathString()
+}
+
+// pathString returns the string representation of the current path
+func (st *state) pathString() string {
+	return formatPath(st.path)
+}
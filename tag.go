@@ -2,7 +2,6 @@ package structmapper
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 	"unicode"
 )
@@ -62,19 +61,12 @@ func IsInvalidTag(err error) (*InvalidTag, bool) {
 	return it, ok
 }
 
-// parseTagFromStructField is a helper that calls parseTag given a reflect.StructField and a tag name
-func parseTagFromStructField(f reflect.StructField, tagName string) (name string, omitEmpty bool, err error) {
-	tag := f.Tag.Get(tagName)
-	name, omitEmpty, err = parseTag(tag)
-	if name == "" {
-		name = f.Name
-	}
-	return
-}
-
-// parseTag parses a tag string and returns the corresponding name, omitEmpty flag and a possible
-// error
-func parseTag(tag string) (name string, omitEmpty bool, err error) {
+// parseTag parses a tag string and returns the corresponding name, omitEmpty flag, slice element key and a
+// possible error.
+//
+// The name may be followed by the options "omitempty" and "key=<name>", separated by commas. The latter
+// defines the key of the field identifying the elements of a slice, which is used by Diff.
+func parseTag(tag string) (name string, omitEmpty bool, key string, err error) {
 	name = tag
 
 	// Handle the "ignore me" tag value
@@ -82,20 +74,36 @@ func parseTag(tag string) (name string, omitEmpty bool, err error) {
 		return
 	}
 
-	// Check if the tag has the omitempty suffix set
-	if strings.HasSuffix(tag, ",omitempty") {
-		// Update the omitEmpty flag and strip the suffix from the tag name
-		omitEmpty = true
-		name = strings.TrimSuffix(tag, ",omitempty")
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		// Strip the options from the tag name and parse them
+		name = tag[:i]
+		for _, option := range strings.Split(tag[i+1:], ",") {
+			switch {
+			case option == "omitempty":
+				omitEmpty = true
+			case len(option) > len("key=") && strings.HasPrefix(option, "key=") &&
+				isValidTagName(strings.TrimPrefix(option, "key=")):
+				key = strings.TrimPrefix(option, "key=")
+			default:
+				err = newErrorInvalidTag(tag)
+			}
+		}
 	}
 
 	// Check if the rest of the tag does not contain any symbols
+	if !isValidTagName(name) {
+		err = newErrorInvalidTag(tag)
+	}
+
+	return
+}
+
+// isValidTagName checks if name consists of letters, digits and underscores only
+func isValidTagName(name string) bool {
 	for _, letter := range name {
 		if letter != '_' && !unicode.IsLetter(letter) && !unicode.IsDigit(letter) {
-			err = newErrorInvalidTag(tag)
-			return
+			return false
 		}
 	}
-
-	return
+	return true
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"testing"
 
-	"github.com/hashicorp/go-multierror"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -26,10 +25,11 @@ func TestOptionTagName(t *testing.T) {
 		require.Error(t, err)
 		require.Nil(t, sm)
 
-		require.IsType(t, &multierror.Error{}, err)
-		multiErr := err.(*multierror.Error)
-		assert.Len(t, multiErr.WrappedErrors(), 1)
-		assert.EqualError(t, multiErr.WrappedErrors()[0], ErrTagNameEmpty.Error())
+		require.IsType(t, &MultiError{}, err)
+		multiErr := err.(*MultiError)
+		assert.Len(t, multiErr.Errors(), 1)
+		assert.EqualError(t, multiErr.Errors()[0], ErrTagNameEmpty.Error())
+		assert.True(t, errors.Is(err, ErrTagNameEmpty))
 	})
 
 }
@@ -37,7 +37,7 @@ func TestOptionTagName(t *testing.T) {
 func TestParseTag(t *testing.T) {
 	t.Run("Dash", func(t *testing.T) {
 		// Check if the special-case ignore-me tag ("-") gives the correct result
-		name, omitEmpty, err := parseTag("-")
+		name, omitEmpty, _, err := parseTag("-")
 		assert.EqualValues(t, "-", name)
 		assert.EqualValues(t, false, omitEmpty)
 		assert.NoError(t, err)
@@ -45,7 +45,7 @@ func TestParseTag(t *testing.T) {
 
 	t.Run("OmitEmptyNoTagName", func(t *testing.T) {
 		// Check if ",omitEmpty" alone works
-		name, omitEmpty, err := parseTag(",omitempty")
+		name, omitEmpty, _, err := parseTag(",omitempty")
 		assert.EqualValues(t, "", name)
 		assert.EqualValues(t, true, omitEmpty)
 		assert.NoError(t, err)
@@ -53,7 +53,7 @@ func TestParseTag(t *testing.T) {
 
 	t.Run("OmitEmpty", func(t *testing.T) {
 		// Check if "name,omitEmpty" returns the correct tag name
-		name, omitEmpty, err := parseTag("test,omitempty")
+		name, omitEmpty, _, err := parseTag("test,omitempty")
 		assert.EqualValues(t, "test", name)
 		assert.EqualValues(t, true, omitEmpty)
 		assert.NoError(t, err)
@@ -61,7 +61,7 @@ func TestParseTag(t *testing.T) {
 
 	t.Run("Puncation", func(t *testing.T) {
 		// Check if a punctation inside the tag name gives an error
-		name, omitEmpty, err := parseTag("test.,omitempty")
+		name, omitEmpty, _, err := parseTag("test.,omitempty")
 		assert.EqualValues(t, "test.", name)
 		assert.EqualValues(t, true, omitEmpty)
 		assert.Error(t, err)
@@ -73,7 +73,7 @@ func TestParseTag(t *testing.T) {
 
 	t.Run("Whitespace", func(t *testing.T) {
 		// Check if whitespace inside the tag name gives an error
-		name, omitEmpty, err := parseTag("test ,omitempty")
+		name, omitEmpty, _, err := parseTag("test ,omitempty")
 		assert.EqualValues(t, "test ", name)
 		assert.EqualValues(t, true, omitEmpty)
 		assert.Error(t, err)
@@ -85,12 +85,30 @@ func TestParseTag(t *testing.T) {
 
 	t.Run("Underscores", func(t *testing.T) {
 		// Check if underscores are allowed
-		name, omitEmpty, err := parseTag("test_tag")
+		name, omitEmpty, _, err := parseTag("test_tag")
 		assert.NoError(t, err)
 		assert.EqualValues(t, "test_tag", name)
 		assert.EqualValues(t, false, omitEmpty)
 	})
 
+	t.Run("Key", func(t *testing.T) {
+		// Check if the key option is parsed along with omitempty
+		name, omitEmpty, key, err := parseTag("test,key=name,omitempty")
+		assert.NoError(t, err)
+		assert.EqualValues(t, "test", name)
+		assert.EqualValues(t, true, omitEmpty)
+		assert.EqualValues(t, "name", key)
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		// Check if unknown options and invalid keys give an error
+		for _, tag := range []string{"test,unknown", "test,key=", "test,key=a.b"} {
+			_, _, _, err := parseTag(tag)
+			require.IsType(t, &InvalidTag{}, err, tag)
+			assert.EqualValues(t, tag, err.(*InvalidTag).Tag())
+		}
+	})
+
 }
 
 func TestIsInvalidTag(t *testing.T) {
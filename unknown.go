@@ -0,0 +1,66 @@
+package structmapper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file contains the unknown key handling of Mapper
+
+var _ error = (*UnknownKeysError)(nil)
+
+// UnknownKeysError is an error that indicates that the source map passed to ToStruct contained keys
+// which do not correspond to any field, if enabled using OptionErrorOnUnknownKeys
+type UnknownKeysError struct {
+	keys []string
+}
+
+// Error returns the error string and causes UnknownKeysError to implement the error interface
+func (uke *UnknownKeysError) Error() string {
+	return fmt.Sprintf("Unknown keys: %s", strings.Join(uke.keys, ", "))
+}
+
+// Keys returns the sorted paths of the unknown keys
+func (uke *UnknownKeysError) Keys() []string {
+	return uke.keys
+}
+
+func newErrorUnknownKeys(keys []string) error {
+	return &UnknownKeysError{
+		keys: keys,
+	}
+}
+
+// IsUnknownKeysError checks if the given error is a UnknownKeysError
+// and returns the UnknownKeysError along with a boolean that defines
+// if it is indeed an unknown keys error.
+// The returned *UnknownKeysError may be nil, if the flag is false
+func IsUnknownKeysError(err error) (*UnknownKeysError, bool) {
+	uke, ok := err.(*UnknownKeysError)
+	return uke, ok
+}
+
+// collectUnknownKeys records the paths of all keys of in which do not correspond to any of the fields
+func (st *state) collectUnknownKeys(in reflect.Value, fields *structFields) {
+	for _, key := range in.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		if _, ok := fields.byName[name]; ok {
+			continue
+		}
+
+		st.unknownKeys = append(st.unknownKeys, st.childPath(name))
+		st.recordUnused(name)
+	}
+}
+
+// checkUnknownKeys returns an error if any unknown keys have been encountered
+func (sm *Mapper) checkUnknownKeys(st *state) error {
+	if !sm.errorOnUnknownKeys || len(st.unknownKeys) == 0 {
+		return nil
+	}
+
+	sort.Strings(st.unknownKeys)
+	return newErrorUnknownKeys(st.unknownKeys)
+}
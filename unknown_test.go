@@ -0,0 +1,120 @@
+package structmapper_test
+
+import (
+	"testing"
+
+	"github.com/anexia-it/go-structmapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapper_ErrorOnUnknownKeys(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := &mapperTestStructSimple{}
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"eff":     "test",
+			"timeuot": 1,
+		}, target))
+		require.EqualValues(t, "test", target.A)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionErrorOnUnknownKeys(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := map[string]interface{}{
+			"A":       "test0",
+			"timeuot": 1,
+			"B": map[string]interface{}{
+				"A": "test1",
+				"B": []interface{}{
+					map[string]interface{}{
+						"eff": "test2",
+					},
+					map[string]interface{}{
+						"eff":  "test3",
+						"ef":   "test3",
+						"EFF ": "test3",
+					},
+				},
+			},
+		}
+
+		target := &mapperTestStructNestedNestedStructSlice{}
+		err = sm.ToStruct(source, target)
+		require.Error(t, err)
+		uke, ok := structmapper.IsUnknownKeysError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *UnknownKeysError")
+		require.EqualValues(t, []string{"B.B[1].EFF ", "B.B[1].ef", "timeuot"}, uke.Keys())
+		require.EqualError(t, err, "Unknown keys: B.B[1].EFF , B.B[1].ef, timeuot")
+		require.EqualValues(t, &mapperTestStructNestedNestedStructSlice{}, target)
+
+		// Without the unknown keys the source is accepted
+		delete(source, "timeuot")
+		elem := source["B"].(map[string]interface{})["B"].([]interface{})[1].(map[string]interface{})
+		delete(elem, "ef")
+		delete(elem, "EFF ")
+		require.NoError(t, sm.ToStruct(source, target))
+		require.EqualValues(t, &mapperTestStructNestedNestedStructSlice{
+			A: "test0",
+			B: mapperTestStructNestedStructSlice{
+				A: "test1",
+				B: []mapperTestStructSimple{
+					{
+						A: "test2",
+					},
+					{
+						A: "test3",
+					},
+				},
+			},
+		}, target)
+	})
+
+	t.Run("Embedded", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionErrorOnUnknownKeys(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Keys of promoted fields are known, while ambiguous fields are not
+		target := &MapperTestStructPromotionOuter{}
+		err = sm.ToStruct(map[string]interface{}{
+			"a": "a",
+			"C": "c",
+			"b": "b",
+		}, target)
+		uke, ok := structmapper.IsUnknownKeysError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *UnknownKeysError")
+		require.EqualValues(t, []string{"b"}, uke.Keys())
+
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"a": "a",
+			"C": "c",
+		}, target))
+		require.EqualValues(t, "a", target.A)
+		require.EqualValues(t, "c", target.MapperTestStructPromotionInnerB.C)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionErrorOnUnknownKeys(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := newMapperTestStructMerge()
+		changes, err := sm.Patch(map[string]interface{}{
+			"name": "patched",
+			"inner": map[string]interface{}{
+				"c": "unknown",
+			},
+		}, target)
+		require.Nil(t, changes)
+		uke, ok := structmapper.IsUnknownKeysError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *UnknownKeysError")
+		require.EqualValues(t, []string{"inner.c"}, uke.Keys())
+		require.EqualValues(t, newMapperTestStructMerge(), target)
+	})
+}
@@ -4,44 +4,86 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"unicode"
 
 	"encoding"
-
-	"github.com/hashicorp/go-multierror"
 )
 
 // This file contains the map to struct functionality of Mapper
 
-func (sm *Mapper) unmapPtr(in interface{}, out reflect.Value, t reflect.Type) error {
-	child := reflect.New(t.Elem())
-	if err := sm.unmapValue(in, child.Elem(), child.Elem().Type()); err != nil {
+func (sm *Mapper) unmapPtr(st *state, in interface{}, out reflect.Value, t reflect.Type) error {
+	var child reflect.Value
+	if sm.references {
+		if ref, ok := referenceOf(in); ok {
+			// Input is a reference marker: point to the referenced value
+			return st.setReference(ref, out, t)
+		}
+	}
+
+	if st.merge.enabled && !out.IsNil() {
+		// Merge onto the value the existing pointer points to
+		return sm.mergePtr(st, in, out)
+	}
+	defer st.pauseChanges()()
+
+	if sm.references {
+		var err error
+		if child, err = st.claimTarget(t); err != nil {
+			return err
+		}
+	} else {
+		child = reflect.New(t.Elem())
+	}
+
+	if err := sm.unmapValue(st, in, child.Elem(), child.Elem().Type()); err != nil {
 		return err
 	}
 	out.Set(child)
 	return nil
 }
 
-func (sm *Mapper) unmapSlice(in interface{}, out reflect.Value, t reflect.Type) (err error) {
+func (sm *Mapper) unmapSlice(st *state, in interface{}, out reflect.Value, t reflect.Type) (err error) {
 	inSlice := reflect.ValueOf(in)
 	if inSlice.Kind() != reflect.Slice {
-		return errors.New("Not a slice")
+		return ErrNotASlice
 	}
 
-	outSlice := reflect.MakeSlice(t, inSlice.Len(), inSlice.Cap())
+	if err = sm.checkLength(st, inSlice.Len()); err != nil {
+		return
+	}
+
+	// offset is the index of the first output element corresponding to an input element
+	outSlice, offset := sm.newSlice(st, out, t, inSlice.Len())
+	if !sm.mergesElements(st, out) {
+		defer st.pauseChanges()()
+	}
 	for i := 0; i < inSlice.Len(); i++ {
-		inElem := inSlice.Index(i)
-		outElem := outSlice.Index(i)
+		outElem := outSlice.Index(offset + i)
 
-		inV := reflect.ValueOf(inElem.Interface())
+		// Start with a copy of the existing element, which is the zero value unless merging by index
 		elemV := reflect.New(outElem.Type()).Elem()
+		elemV.Set(outElem)
 
-		if unmapErr := sm.unmapValue(inV.Interface(), elemV, elemV.Type()); unmapErr != nil {
-			err = multierror.Append(err, multierror.Prefix(unmapErr, fmt.Sprintf("@%d", i)))
+		st.pushIndex(offset + i)
+		unmapErr := sm.unmapValue(st, inSlice.Index(i).Interface(), elemV, elemV.Type())
+		if unmapErr == errSkipValue {
+			// Element is skipped due to the KindPolicy, leave it untouched
+			unmapErr = nil
+		}
+		if unmapErr == nil {
+			st.trackChange(existingElement(out, offset+i), elemV)
+		} else {
+			unmapErr = st.fieldError(unmapErr, elemV.Type(), inSlice.Index(i).Interface())
+		}
+		st.pop()
+		if unmapErr != nil {
+			err = appendErrors(err, unmapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
 
-		outSlice.Index(i).Set(elemV)
+		outElem.Set(elemV)
 	}
 
 	if err == nil {
@@ -51,32 +93,84 @@ func (sm *Mapper) unmapSlice(in interface{}, out reflect.Value, t reflect.Type)
 	return
 }
 
-func (sm *Mapper) unmapMap(in interface{}, out reflect.Value, t reflect.Type) (err error) {
+func (sm *Mapper) unmapMap(st *state, in interface{}, out reflect.Value, t reflect.Type) (err error) {
 	inMap := reflect.ValueOf(in)
 	if inMap.Kind() != reflect.Map {
 		return errors.New("Not a map")
 	}
 
-	outMap := reflect.MakeMap(t)
+	if err = sm.checkLength(st, inMap.Len()); err != nil {
+		return
+	}
 
-	for _, inKeyElem := range inMap.MapKeys() {
+	// In merge mode, entries are added to or overwritten in the existing map
+	merge := st.merge.enabled && !out.IsNil()
+	if !merge {
+		defer st.pauseChanges()()
+	}
 
-		inKeyV := reflect.ValueOf(inKeyElem.Interface())
-		inKeyInterface := inKeyV.Interface()
-		outKey := reflect.New(inKeyV.Type()).Elem()
-		if unmapErr := sm.unmapValue(inKeyInterface, outKey, outKey.Type()); unmapErr != nil {
-			err = multierror.Append(err, multierror.Prefix(unmapErr, fmt.Sprintf("@%+v (key)", inKeyInterface)))
+	outMap := reflect.MakeMap(t)
+	var removedKeys []reflect.Value
+
+	// Process the entries sorted by key, so errors are reported in a deterministic order
+	for _, inKeyElem := range sortedMapKeys(inMap) {
+		inKeyInterface := inKeyElem.Interface()
+		outKey := reflect.New(t.Key()).Elem()
+		st.push(fmt.Sprint(inKeyInterface))
+		unmapErr := sm.unmapValue(st, inKeyInterface, outKey, outKey.Type())
+		if unmapErr != nil {
+			unmapErr = st.fieldError(unmapErr, outKey.Type(), inKeyInterface)
+		}
+		st.pop()
+		if unmapErr == errSkipValue {
+			// Entry is skipped due to the KindPolicy
+			continue
+		} else if unmapErr != nil {
+			err = appendErrors(err, unmapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
-		inValueElem := inMap.MapIndex(inKeyV)
-		inValueV := reflect.ValueOf(inValueElem.Interface())
-		inValueInterface := inValueV.Interface()
+		inValueInterface := inMap.MapIndex(inKeyElem).Interface()
+
+		outValue := reflect.New(t.Elem()).Elem()
+		var existing reflect.Value
+		if merge {
+			// Start with a copy of the existing entry, if any
+			if existing = out.MapIndex(outKey); existing.IsValid() {
+				outValue.Set(existing)
+			}
 
-		outValue := reflect.New(outMap.Type().Elem()).Elem()
+			if inValueInterface == nil {
+				// nil removes the entry
+				st.push(fmt.Sprint(inKeyInterface))
+				st.trackChange(existing, reflect.Value{})
+				st.pop()
+				removedKeys = append(removedKeys, outKey)
+				continue
+			}
+		} else if st.merge.enabled && inValueInterface == nil {
+			// nil does not add an entry to a new map in merge mode either
+			continue
+		}
 
-		if unmapErr := sm.unmapValue(inValueInterface, outValue, outValue.Type()); unmapErr != nil {
-			err = multierror.Append(err, multierror.Prefix(unmapErr, fmt.Sprintf("@%+v (inValueInterface)",
-				inValueInterface)))
+		st.push(fmt.Sprint(inKeyInterface))
+		unmapErr = sm.unmapValue(st, inValueInterface, outValue, outValue.Type())
+		if unmapErr == nil {
+			st.trackChange(existing, outValue)
+		} else {
+			unmapErr = st.fieldError(unmapErr, outValue.Type(), inValueInterface)
+		}
+		st.pop()
+		if unmapErr == errSkipValue {
+			// Entry is skipped due to the KindPolicy, like with ToMap
+			continue
+		} else if unmapErr != nil {
+			err = appendErrors(err, unmapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
 
@@ -84,28 +178,64 @@ func (sm *Mapper) unmapMap(in interface{}, out reflect.Value, t reflect.Type) (e
 	}
 
 	if err == nil {
-		// Special case: out may be a struct or struct pointer...
-		out.Set(outMap)
+		if merge {
+			sm.mergeMap(st, out, outMap, removedKeys)
+		} else {
+			out.Set(outMap)
+		}
 	}
 
 	return
 }
 
-func (sm *Mapper) unmapArray(in interface{}, out reflect.Value, t reflect.Type) (err error) {
+func (sm *Mapper) unmapArray(st *state, in interface{}, out reflect.Value, t reflect.Type) (err error) {
 	inArray := reflect.ValueOf(in)
 
 	if inArray.Kind() != reflect.Array && inArray.Kind() != reflect.Slice {
 		return errors.New("Not an array or slice")
 	}
 
+	if err = sm.checkLength(st, inArray.Len()); err != nil {
+		return
+	}
+
+	if inArray.Len() > t.Len() {
+		return ErrArrayTooLong
+	}
+
 	outArray := reflect.New(t).Elem()
+	if st.merge.enabled && !st.merge.replaceLists {
+		// Arrays are always merged by index
+		outArray.Set(out)
+	}
 
 	for i := 0; i < inArray.Len(); i++ {
 		outElem := outArray.Index(i)
-		inValue := inArray.Index(i)
+		inValue := inArray.Index(i).Interface()
 
-		if unmapErr := sm.unmapValue(inValue.Interface(), outElem, outElem.Type()); unmapErr != nil {
-			err = multierror.Append(err, multierror.Prefix(unmapErr, fmt.Sprintf("@%d", i)))
+		var oldElem reflect.Value
+		if st.merge.trackChanges {
+			oldElem = reflect.New(outElem.Type()).Elem()
+			oldElem.Set(outElem)
+		}
+
+		st.pushIndex(i)
+		unmapErr := sm.unmapValue(st, inValue, outElem, outElem.Type())
+		if unmapErr == errSkipValue {
+			// Element is skipped due to the KindPolicy, leave it untouched
+			unmapErr = nil
+		}
+		if unmapErr == nil && st.merge.enabled {
+			st.trackChange(oldElem, outElem)
+		} else if unmapErr != nil {
+			unmapErr = st.fieldError(unmapErr, outElem.Type(), inValue)
+		}
+		st.pop()
+		if unmapErr != nil {
+			err = appendErrors(err, unmapErr)
+			if st.done() {
+				return
+			}
 			continue
 		}
 	}
@@ -117,7 +247,7 @@ func (sm *Mapper) unmapArray(in interface{}, out reflect.Value, t reflect.Type)
 	return
 }
 
-func (sm *Mapper) unmapUnmarshal(in interface{}, out reflect.Value) (bool, error) {
+func (sm *Mapper) unmapUnmarshal(st *state, in interface{}, out reflect.Value) (bool, error) {
 	inValue := reflect.ValueOf(in)
 	inType := inValue.Type()
 
@@ -135,40 +265,78 @@ func (sm *Mapper) unmapUnmarshal(in interface{}, out reflect.Value) (bool, error
 		return false, nil
 	}
 
-	outI := out.Interface()
+	if out.Kind() == reflect.Ptr && out.Type().Implements(textUnmarshalerType) {
+		// Unmarshal onto a copy, as the value the pointer points to may be shared with the original target
+		target := reflect.New(out.Type().Elem())
+		if !out.IsNil() {
+			target.Elem().Set(out.Elem())
+		}
+		if err := target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+			return true, err
+		}
 
-	if unmarshaler, ok := outI.(encoding.TextUnmarshaler); ok {
+		if st.merge.enabled && !out.IsNil() {
+			// Keep the existing pointer in merge mode
+			existing := out.Elem()
+			st.trackChange(existing, target.Elem())
+			st.deferChange(func() {
+				existing.Set(target.Elem())
+			})
+		} else {
+			out.Set(target)
+		}
+		return true, nil
+	}
+
+	if unmarshaler, ok := out.Interface().(encoding.TextUnmarshaler); ok {
 		return true, unmarshaler.UnmarshalText([]byte(str))
 	} else if out.CanAddr() {
-		return sm.unmapUnmarshal(in, out.Addr())
+		if unmarshaler, ok := out.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return true, unmarshaler.UnmarshalText([]byte(str))
+		}
 	}
 
 	return false, nil
 }
 
-func (sm *Mapper) unmapValue(in interface{}, out reflect.Value, t reflect.Type) error {
+func (sm *Mapper) unmapValue(st *state, in interface{}, out reflect.Value, t reflect.Type) error {
+	if err := sm.checkDepth(st); err != nil {
+		return err
+	}
+
+	// Apply the KindPolicy to channel, function and unsafe.Pointer values
+	if err := checkOpaqueKind(sm.kindPolicy, t); err != nil {
+		return err
+	}
+
+	if in == nil {
+		// An explicit nil resets the target to its zero value, as required by Patch
+		out.Set(reflect.Zero(t))
+		return nil
+	}
+
 	// Check if the target implements encoding.TextUnmarshaler
-	if handled, err := sm.unmapUnmarshal(in, out); handled {
+	if handled, err := sm.unmapUnmarshal(st, in, out); handled {
 		return err
 	}
 
 	switch out.Kind() {
 	case reflect.Ptr:
-		return sm.unmapPtr(in, out, t)
+		return sm.unmapPtr(st, in, out, t)
 	case reflect.Struct:
-		return sm.unmapStruct(in, out, t)
+		return sm.unmapStruct(st, in, out, t)
 	case reflect.Slice:
-		return sm.unmapSlice(in, out, t)
+		return sm.unmapSlice(st, in, out, t)
 	case reflect.Map:
-		return sm.unmapMap(in, out, t)
+		return sm.unmapMap(st, in, out, t)
 	case reflect.Array:
-		return sm.unmapArray(in, out, t)
+		return sm.unmapArray(st, in, out, t)
 
 	}
 
 	inValue := reflect.ValueOf(in)
 	inType := inValue.Type()
-	outType := reflect.ValueOf(out.Interface()).Type()
+	outType := out.Type()
 
 	if inType == outType {
 		// Default case: copy the value over
@@ -187,7 +355,7 @@ func (sm *Mapper) unmapValue(in interface{}, out reflect.Value, t reflect.Type)
 	return fmt.Errorf("Type mismatch: %s and %s are incompatible", outType.String(), inType.String())
 }
 
-func (sm *Mapper) unmapStruct(in interface{}, out reflect.Value, t reflect.Type) (err error) {
+func (sm *Mapper) unmapStruct(st *state, in interface{}, out reflect.Value, t reflect.Type) (err error) {
 	if out.Kind() == reflect.Ptr {
 		// Target is a pointer to a struct: create a new instance
 		out.Set(reflect.New(out.Type().Elem()))
@@ -205,80 +373,121 @@ func (sm *Mapper) unmapStruct(in interface{}, out reflect.Value, t reflect.Type)
 		return ErrInvalidMap
 	}
 
+	if err = sm.checkLength(st, inValue.Len()); err != nil {
+		return
+	}
+
+	fields := sm.cachedFields(t)
+	for _, tagErr := range fields.errs {
+		err = appendErrors(err, st.tagError(tagErr))
+		if st.done() {
+			return
+		}
+	}
+
+	if sm.errorOnUnknownKeys || st.metadata != nil {
+		st.collectUnknownKeys(inValue, fields)
+	}
+
 	// Hold the values of the modified fields in a map, which will be applied shortly before
 	// this function returns.
 	// This ensures we do not modify the target struct at all in case of an error
-	modifiedFields := make(map[int]reflect.Value, t.NumField())
-
-	// Iterate over all fields of the passed struct
-	for i := 0; i < out.NumField(); i++ {
-		fieldD := t.Field(i)
-		fieldV := out.Field(i)
-
-		if fieldD.Anonymous {
-			// Call unmapStruct on anonymous field
-			if anonErr := sm.unmapStruct(in,
-				fieldV,
-				fieldD.Type); anonErr != nil {
-				err = multierror.Append(err, anonErr)
-				continue
-			}
-			continue
-		}
+	modifiedFields := make(map[int]reflect.Value, len(fields.list))
 
-		fieldName := fieldD.Name
-
-		if !unicode.IsUpper([]rune(fieldName)[0]) {
-			// Ignore private fields
+	// Iterate over all fields of the passed struct, including the ones promoted from embedded structs
+	for i, f := range fields.list {
+		// Look up value of the field's name in map
+		mapVal := inValue.MapIndex(reflect.ValueOf(f.name))
+		if !mapVal.IsValid() {
+			// Value not in map, ignore it
+			st.recordUnset(f.name)
 			continue
 		}
+		mapValue := mapVal.Interface()
 
-		fieldName, _, tagErr := parseTagFromStructField(fieldD, sm.tagName)
-		if tagErr != nil {
-			// Parsing the tag failed, ignore the field and carry on
-			err = multierror.Append(err, tagErr)
+		if checkOpaqueKind(sm.kindPolicy, f.typ) == errSkipValue {
+			// Field is skipped due to the KindPolicy, leave it untouched
+			st.recordUnused(f.name)
 			continue
 		}
 
-		if fieldName == "-" {
-			// Tag defines that the field shall be ignored, so carry on
+		if f.typ.Kind() == reflect.Interface {
+			// Setting interfaces is unsupported.
+			err = appendErrors(err, st.newFieldError(st.childPath(f.name), f.goName, f.typ,
+				reflect.TypeOf(mapValue), ErrFieldIsInterface))
+			if st.done() {
+				return
+			}
 			continue
 		}
 
-		// Look up value of "fieldName" in map
-		mapVal := inValue.MapIndex(reflect.ValueOf(fieldName))
-		if !mapVal.IsValid() {
-			// Value not in map, ignore it
+		if allocErr := checkFieldByIndex(out, f.index); allocErr != nil {
+			err = appendErrors(err, st.newFieldError(st.childPath(f.name), f.goName, f.typ,
+				reflect.TypeOf(mapValue), allocErr))
+			if st.done() {
+				return
+			}
 			continue
 		}
-		mapValue := mapVal.Interface()
 
-		if fieldV.Kind() == reflect.Interface {
-			// Setting interfaces is unsupported.
-			err = multierror.Append(err, multierror.Prefix(ErrFieldIsInterface, fieldName+":"))
+		// Start with a copy of the existing value in merge mode
+		targetV := reflect.New(f.typ).Elem()
+		oldV, hasOld := fieldByIndex(out, f.index)
+		if st.merge.enabled && hasOld {
+			targetV.Set(oldV)
+		}
+		st.push(f.name)
+		leave := st.enterField(f.goName)
+		unmapErr := sm.unmapValue(st, mapValue, targetV, f.typ)
+		if unmapErr == nil {
+			st.trackChange(oldV, targetV)
+		} else {
+			unmapErr = st.fieldError(unmapErr, f.typ, mapValue)
+		}
+		leave()
+		st.pop()
+		if unmapErr == errSkipValue {
+			// Field is skipped due to the KindPolicy, leave it untouched
+			st.recordUnused(f.name)
 			continue
 		}
-
-		targetV := reflect.New(fieldD.Type).Elem()
-		if unmapErr := sm.unmapValue(mapValue, targetV, fieldD.Type); unmapErr != nil {
-			err = multierror.Append(err, multierror.Prefix(unmapErr, fieldName+":"))
+		if unmapErr == nil {
+			st.recordKey(f.name)
+		}
+		if unmapErr != nil {
+			err = appendErrors(err, unmapErr)
+			if st.done() {
+				return
+			}
 			continue
-		} else {
-			modifiedFields[i] = targetV
 		}
+		modifiedFields[i] = targetV
 	}
 
 	// Apply changes to all modified fields in case no error happened during processing.
 	if err == nil {
-		// Apply changes to all modified fields
-		for fieldIndex, fieldValue := range modifiedFields {
-			out.Field(fieldIndex).Set(fieldValue)
+		// Apply changes to all modified fields, allocating embedded struct pointers as required
+		allocated := make(map[uintptr]bool)
+		for i, f := range fields.list {
+			fieldValue, ok := modifiedFields[i]
+			if !ok {
+				continue
+			}
+
+			st.setFieldByIndex(out, f.index, fieldValue, allocated)
 		}
 	}
 	return
 }
 
 func (sm *Mapper) toStruct(m map[string]interface{}, s interface{}) error {
+	st := sm.newState()
+	st.merge.enabled = sm.merge
+	return sm.unmapRoot(st, m, s)
+}
+
+// unmapRoot maps m onto the struct s points to, using the passed state
+func (sm *Mapper) unmapRoot(st *state, m map[string]interface{}, s interface{}) error {
 	if m == nil {
 		return ErrMapIsNil
 	}
@@ -288,7 +497,34 @@ func (sm *Mapper) toStruct(m map[string]interface{}, s interface{}) error {
 		return ErrNotAStructPointer
 	}
 
+	if sm.references {
+		// Register the root pointer, so references to it can be resolved
+		st.references = newReferenceState()
+		st.references.targets[""] = v
+	}
+
+	// Unmap onto a copy of the target, which is only applied if no error occurred
 	v = v.Elem()
+	targetV := reflect.New(v.Type()).Elem()
+	targetV.Set(v)
 
-	return sm.unmapStruct(m, v, v.Type())
+	if err := sm.unmapStruct(st, m, targetV, targetV.Type()); err != nil {
+		if st.aborted() {
+			// Return the error which aborted the call as-is
+			return st.abortErr
+		}
+		return err
+	}
+
+	if err := st.checkUnresolved(); err != nil {
+		return err
+	}
+
+	if err := sm.checkUnknownKeys(st); err != nil {
+		return err
+	}
+
+	v.Set(targetV)
+	st.commit()
+	return nil
 }
@@ -1,12 +1,12 @@
 package structmapper_test
 
 import (
+	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/anexia-it/go-structmapper"
-	"github.com/hashicorp/errwrap"
-	"github.com/hashicorp/go-multierror"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -49,12 +49,13 @@ func TestMapper_ToStruct(t *testing.T) {
 
 		err = sm.ToStruct(m, target)
 		require.Error(t, err)
-		me, ok := err.(*multierror.Error)
-		require.EqualValues(t, true, ok, "Returned error is not a *multierror.Error")
-		require.Len(t, me.Errors, 1)
-		e := me.Errors[0]
+		me, ok := structmapper.IsMultiError(err)
+		require.EqualValues(t, true, ok, "Returned error is not a *MultiError")
+		require.Len(t, me.Errors(), 1)
+		e := me.Errors()[0]
 		// Test if the error is correct...
-		require.Error(t, e, multierror.Prefix(structmapper.ErrFieldIsInterface, "x: ").Error())
+		require.EqualError(t, e, "x: "+structmapper.ErrFieldIsInterface.Error())
+		require.True(t, errors.Is(err, structmapper.ErrFieldIsInterface))
 	})
 
 	t.Run("Simple", func(t *testing.T) {
@@ -281,9 +282,9 @@ func TestMapper_ToStruct(t *testing.T) {
 
 		err = sm.ToStruct(source, target) //
 		require.Error(t, err)
-		w, ok := err.(errwrap.Wrapper)
-		require.EqualValues(t, true, ok, "returned error is not an errwrap.Wrapper")
-		wrapped := w.WrappedErrors()
+		me, ok := structmapper.IsMultiError(err)
+		require.EqualValues(t, true, ok, "returned error is not a *MultiError")
+		wrapped := me.Errors()
 		require.Len(t, wrapped, 1)
 		require.EqualError(t, wrapped[0], "eff: Type mismatch: string and float64 are incompatible")
 	})
@@ -332,4 +333,339 @@ func TestMapper_ToStruct(t *testing.T) {
 		assert.NoError(t, sm.ToStruct(source, &target))
 		assert.EqualValues(t, expected, target)
 	})
+
+	t.Run("AnonymousPromotion", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		expected := &MapperTestStructPromotionOuter{
+			MapperTestStructPromotionInnerB: &MapperTestStructPromotionInnerB{
+				C: "inner b c",
+			},
+			A: "outer",
+		}
+
+		target := &MapperTestStructPromotionOuter{}
+
+		source := map[string]interface{}{
+			"a": "outer",
+			"b": "ambiguous",
+			"C": "inner b c",
+			"d": "ambiguous",
+		}
+
+		require.NoError(t, sm.ToStruct(source, target))
+		require.EqualValues(t, expected, target)
+	})
+
+	t.Run("TaggedAnonymous", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		expected := &MapperTestStructTaggedAnonymousOuter{
+			MapperTestStructAnonymousInner: MapperTestStructAnonymousInner{
+				A: "inner",
+			},
+			A: "outer",
+		}
+
+		target := &MapperTestStructTaggedAnonymousOuter{}
+
+		source := map[string]interface{}{
+			"meta": map[string]interface{}{
+				"a_inner": "inner",
+			},
+			"a_inner": "not promoted",
+			"a_outer": "outer",
+			"b":       "ignored",
+		}
+
+		require.NoError(t, sm.ToStruct(source, target))
+		require.EqualValues(t, expected, target)
+	})
+
+	t.Run("AnonymousNonStruct", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		expected := &MapperTestStructEmbeddedNonStruct{
+			MapperTestTags: MapperTestTags{"t0", "t1"},
+			A:              "a",
+		}
+
+		target := &MapperTestStructEmbeddedNonStruct{}
+
+		source := map[string]interface{}{
+			"MapperTestTags": []interface{}{"t0", "t1"},
+			"a":              "a",
+		}
+
+		require.NoError(t, sm.ToStruct(source, target))
+		require.EqualValues(t, expected, target)
+
+		// Embedded interfaces cannot be set, just like interface fields
+		source["MapperTestStringer"] = "stringer"
+		require.Error(t, sm.ToStruct(source, &MapperTestStructEmbeddedNonStruct{}))
+	})
+
+	t.Run("AnonymousTextMarshaler", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		expected := &MapperTestStructEmbeddedTime{
+			Time: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+			A:    "a",
+		}
+
+		target := &MapperTestStructEmbeddedTime{}
+
+		source := map[string]interface{}{
+			"Time": "2021-06-01T12:00:00Z",
+			"a":    "a",
+		}
+
+		require.NoError(t, sm.ToStruct(source, target))
+		require.True(t, expected.Time.Equal(target.Time))
+		require.EqualValues(t, expected.A, target.A)
+
+		// Nested, the struct is parsed from text through the promoted UnmarshalText method
+		holder := &mapperTestStructEmbeddedTimeHolder{}
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"w": "2021-06-01T12:00:00Z",
+		}, holder))
+		require.True(t, expected.Time.Equal(holder.W.Time))
+		require.Empty(t, holder.W.A)
+	})
+
+	t.Run("AnonymousPtrLazy", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		target := &MapperTestStructAnonymousPtrOuter{}
+
+		// No key of the embedded struct is present, so the pointer stays nil
+		source := map[string]interface{}{
+			"a_outer": "outer",
+		}
+
+		require.NoError(t, sm.ToStruct(source, target))
+		require.Nil(t, target.MapperTestStructAnonymousInner)
+		require.EqualValues(t, "outer", target.A)
+
+		// The embedded pointer is not allocated if setting its fields fails
+		source = map[string]interface{}{
+			"a_inner": 1.5,
+		}
+
+		require.Error(t, sm.ToStruct(source, target))
+		require.Nil(t, target.MapperTestStructAnonymousInner)
+	})
+
+	t.Run("AnonymousPtrReuse", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		inner := &MapperTestStructAnonymousInner{}
+		target := &MapperTestStructAnonymousPtrOuter{
+			MapperTestStructAnonymousInner: inner,
+		}
+
+		source := map[string]interface{}{
+			"a_inner": "inner",
+		}
+
+		// The existing embedded pointer is reused
+		require.NoError(t, sm.ToStruct(source, target))
+		require.True(t, inner == target.MapperTestStructAnonymousInner)
+		require.EqualValues(t, "inner", inner.A)
+	})
+
+	t.Run("AnonymousPrivatePtr", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		source := map[string]interface{}{
+			"a_outer": "outer",
+			"b_inner": "inner",
+		}
+
+		// A nil pointer to an unexported embedded struct cannot be allocated
+		target := &MapperTestStructAnonymousPrivatePtrOuter{}
+		require.Error(t, sm.ToStruct(source, target))
+		require.Nil(t, target.mapperTestStructAnonymousPrivateInner)
+		require.Empty(t, target.A)
+
+		// Setting the fields of an existing one works
+		target = &MapperTestStructAnonymousPrivatePtrOuter{
+			mapperTestStructAnonymousPrivateInner: &mapperTestStructAnonymousPrivateInner{},
+		}
+		require.NoError(t, sm.ToStruct(source, target))
+		require.EqualValues(t, "inner", target.B)
+		require.EqualValues(t, "outer", target.A)
+	})
+
+	t.Run("NilResets", func(t *testing.T) {
+		// Initialize Mapper without options
+		sm, err := structmapper.NewMapper()
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// nil values reset the existing values to their zero values, which Patch relies on for explicit nils
+		expected := &mapperTestStructNested{
+			C: 2.1,
+			D: 3,
+		}
+
+		m := map[string]interface{}{
+			"a":   nil,
+			"b":   nil,
+			"c":   2.1,
+			"dee": uint64(3),
+			"e":   nil,
+		}
+
+		target := &mapperTestStructNested{
+			A: "0",
+			B: 1,
+			E: &mapperTestStructSimple{
+				A: "4",
+			},
+		}
+
+		require.NoError(t, sm.ToStruct(m, target))
+		require.EqualValues(t, expected, target)
+	})
+}
+
+func TestMapper_ToStructAtomic(t *testing.T) {
+	// Each source is valid, except for a single value at a different depth
+	sources := map[string]map[string]interface{}{
+		"Root": {
+			"a":  "modified",
+			"ip": "10.0.0.1",
+			"c":  "invalid",
+		},
+		"Middle": {
+			"c": 2.5,
+			"middle": map[string]interface{}{
+				"a": "modified",
+				"inner": map[string]interface{}{
+					"ip": "10.0.0.1",
+				},
+				"b": "invalid",
+			},
+		},
+		"Items": {
+			"a": "modified",
+			"items": []interface{}{
+				map[string]interface{}{
+					"a": "modified",
+					"inner": map[string]interface{}{
+						"a": "modified",
+					},
+				},
+				map[string]interface{}{
+					"inner": map[string]interface{}{
+						"ip": "invalid",
+					},
+				},
+			},
+		},
+		"Unresolved": {
+			"a": "modified",
+			"middle": map[string]interface{}{
+				"a": "modified",
+				"inner": map[string]interface{}{
+					structmapper.RefKey: "missing",
+				},
+			},
+		},
+		"Unknown": {
+			"a": "modified",
+			"middle": map[string]interface{}{
+				"a":  "modified",
+				"ip": "10.0.0.1",
+				"inner": map[string]interface{}{
+					"a":       "modified",
+					"timeuot": 1,
+				},
+			},
+		},
+	}
+
+	optionSets := map[string][]structmapper.Option{
+		"Replace": {
+			structmapper.OptionReferences(true),
+			structmapper.OptionErrorOnUnknownKeys(true),
+		},
+		"Merge": {
+			structmapper.OptionReferences(true),
+			structmapper.OptionErrorOnUnknownKeys(true),
+			structmapper.OptionMerge(true),
+		},
+		"MergeByIndex": {
+			structmapper.OptionReferences(true),
+			structmapper.OptionErrorOnUnknownKeys(true),
+			structmapper.OptionMerge(true),
+			structmapper.OptionSliceStrategy(structmapper.SliceMergeByIndex),
+		},
+	}
+
+	for optionsName, options := range optionSets {
+		sm, err := structmapper.NewMapper(options...)
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		for sourceName, source := range sources {
+			t.Run(optionsName+"/"+sourceName, func(t *testing.T) {
+				target := newMapperTestStructAtomicOuter()
+				embedded := target.MapperTestStructAtomicInner
+				middleInner := target.Middle.Inner
+				ip := target.IP
+
+				require.Error(t, sm.ToStruct(source, target))
+				require.EqualValues(t, newMapperTestStructAtomicOuter(), target)
+				require.True(t, embedded == target.MapperTestStructAtomicInner)
+				require.True(t, middleInner == target.Middle.Inner)
+				require.True(t, ip == target.IP)
+
+				_, err := sm.Patch(source, target)
+				require.Error(t, err)
+				require.EqualValues(t, newMapperTestStructAtomicOuter(), target)
+			})
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		sm, err := structmapper.NewMapper(structmapper.OptionMerge(true))
+		require.NoError(t, err)
+		require.NotNil(t, sm)
+
+		// Existing embedded pointers and TextUnmarshaler pointers are modified once the call succeeded
+		target := newMapperTestStructAtomicOuter()
+		embedded := target.MapperTestStructAtomicInner
+		ip := target.IP
+		require.NoError(t, sm.ToStruct(map[string]interface{}{
+			"a":  "modified",
+			"ip": "10.0.0.1",
+		}, target))
+		require.True(t, embedded == target.MapperTestStructAtomicInner)
+		require.True(t, ip == target.IP)
+		require.EqualValues(t, "modified", target.A)
+		require.EqualValues(t, "10.0.0.1", target.IP.String())
+	})
 }
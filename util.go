@@ -3,6 +3,8 @@ package structmapper
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 )
 
 // This file contains utility functions
@@ -21,6 +23,49 @@ func IsNilOrEmpty(i interface{}, v reflect.Value) bool {
 	return reflect.DeepEqual(i, reflect.Zero(v.Type()).Interface())
 }
 
+// sortedMapKeys returns the keys of the map v in ascending order.
+// Numbers, strings and booleans are compared by value, all other keys and keys of different kinds are
+// compared by their string representation.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessMapKey(keys[i], keys[j])
+	})
+	return keys
+}
+
+// lessMapKey checks if the map key a sorts before b
+func lessMapKey(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+	}
+
+	aString, bString := fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface())
+	if aString != bString {
+		return aString < bString
+	}
+	// Keys of different types with the same representation, like 1 and "1"
+	return a.Type().String() < b.Type().String()
+}
+
 // ForceStringMapKeys takes a map[string]interface{} and ensures that all maps which are nested
 // in this top-level map are of type map[string]interface{}.
 // The map that is passed in is expected to contain only primitive types, maps, slices and arrays.
@@ -30,23 +75,39 @@ func IsNilOrEmpty(i interface{}, v reflect.Value) bool {
 //
 // Keys which are not strings already are converted to strings by either using the key's String() method,
 // if available, converting via reflect conversion or falling back to using fmt.Sprint() for conversion.
+//
+// Channel, function and unsafe.Pointer values are skipped, like KindPolicySkip does: map entries holding
+// them are omitted, while slice and array elements holding them are set to nil.
+// Use Mapper.ForceStringMapKeys for applying a different KindPolicy.
 func ForceStringMapKeys(in map[string]interface{}) (out map[string]interface{}, err error) {
+	return forceStringMapKeys(in, KindPolicySkip)
+}
+
+func forceStringMapKeys(in map[string]interface{}, policy KindPolicy) (out map[string]interface{}, err error) {
+	keys := make([]string, 0, len(in))
+	for key := range in {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	out = make(map[string]interface{}, len(in))
-	for key, value := range in {
-		if out[key], err = convertToStringKeys(value); err != nil {
+	for _, key := range keys {
+		value := in[key]
+		var converted interface{}
+		if converted, err = convertValueToStringKeys(reflect.ValueOf(value), policy); err == errSkipValue {
+			err = nil
+			continue
+		} else if err != nil {
 			out = nil
 			return
 		}
+		out[key] = converted
 	}
 
 	return
 }
 
-func convertToStringKeys(in interface{}) (out interface{}, err error) {
-	return convertValueToStringKeys(reflect.ValueOf(in))
-}
-
-func convertValueToStringKeys(in reflect.Value) (out interface{}, err error) {
+func convertValueToStringKeys(in reflect.Value, policy KindPolicy) (out interface{}, err error) {
 	if !in.IsValid() || in.Interface() == nil {
 		return nil, nil
 	}
@@ -54,23 +115,28 @@ func convertValueToStringKeys(in reflect.Value) (out interface{}, err error) {
 	inKind := in.Kind()
 	switch inKind {
 	case reflect.Map:
-		out, err = convertMapToStringKeys(in)
+		out, err = convertMapToStringKeys(in, policy)
 		return
 	case reflect.Slice:
-		out, err = convertSliceToStringKeys(in)
+		out, err = convertSliceToStringKeys(in, policy)
 		return
 	case reflect.Array:
-		out, err = convertArrayToStringKeys(in)
+		out, err = convertArrayToStringKeys(in, policy)
 		return
 	case reflect.Interface:
-		out, err = convertValueToStringKeys(in.Elem())
+		out, err = convertValueToStringKeys(in.Elem(), policy)
 		return
-	case reflect.Struct, reflect.Chan, reflect.Func:
+	case reflect.Struct:
 		err = fmt.Errorf("Conversion of type %s (kind: %s) is not supported", in.Type().String(),
 			inKind.String())
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		// errSkipValue or an error wrapping ErrUnsupportedKind, unless the values are passed through
+		if err = checkOpaqueKind(policy, in.Type()); err != nil {
+			return
+		}
 	case reflect.Ptr:
 		if !in.IsNil() {
-			out, err = convertValueToStringKeys(in.Elem())
+			out, err = convertValueToStringKeys(in.Elem(), policy)
 			return
 		}
 
@@ -82,7 +148,7 @@ func convertValueToStringKeys(in reflect.Value) (out interface{}, err error) {
 	return
 }
 
-func convertSliceToStringKeys(in reflect.Value) (out interface{}, err error) {
+func convertSliceToStringKeys(in reflect.Value, policy KindPolicy) (out interface{}, err error) {
 	// No-op for empty slices
 	if in.Len() == 0 {
 		return in.Interface(), nil
@@ -92,18 +158,22 @@ func convertSliceToStringKeys(in reflect.Value) (out interface{}, err error) {
 
 	for i := 0; i < in.Len(); i++ {
 		var val interface{}
-		if val, err = convertValueToStringKeys(in.Index(i)); err != nil {
+		if val, err = convertValueToStringKeys(in.Index(i), policy); err == errSkipValue {
+			// Skipped elements are left at their zero value
+			err = nil
+			continue
+		} else if err != nil {
 			return
 		}
 
-		outSlice.Index(i).Set(reflect.ValueOf(val))
+		setConvertedElement(outSlice.Index(i), val)
 	}
 
 	out = outSlice.Interface()
 	return
 }
 
-func convertArrayToStringKeys(in reflect.Value) (out interface{}, err error) {
+func convertArrayToStringKeys(in reflect.Value, policy KindPolicy) (out interface{}, err error) {
 	// No-op for empty arrays
 	if in.Len() == 0 {
 		return in.Interface(), nil
@@ -113,20 +183,33 @@ func convertArrayToStringKeys(in reflect.Value) (out interface{}, err error) {
 
 	for i := 0; i < in.Len(); i++ {
 		var val interface{}
-		if val, err = convertValueToStringKeys(in.Index(i)); err != nil {
+		if val, err = convertValueToStringKeys(in.Index(i), policy); err == errSkipValue {
+			// Skipped elements are left at their zero value
+			err = nil
+			continue
+		} else if err != nil {
 			return
 		}
 
-		outArray.Index(i).Set(reflect.ValueOf(val))
+		setConvertedElement(outArray.Index(i), val)
 	}
 
 	out = outArray.Interface()
 	return
 }
 
-func convertMapToStringKeys(in reflect.Value) (out map[string]interface{}, err error) {
+// setConvertedElement sets a slice or array element to a converted value, using the zero value for nil
+func setConvertedElement(elem reflect.Value, val interface{}) {
+	if val == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return
+	}
+	elem.Set(reflect.ValueOf(val))
+}
+
+func convertMapToStringKeys(in reflect.Value, policy KindPolicy) (out map[string]interface{}, err error) {
 	stringType := reflect.TypeOf("")
-	inKeys := in.MapKeys()
+	inKeys := sortedMapKeys(in)
 	out = make(map[string]interface{}, len(inKeys))
 	for _, key := range inKeys {
 		var keyString string
@@ -165,11 +248,55 @@ func convertMapToStringKeys(in reflect.Value) (out map[string]interface{}, err e
 			keyString = fmt.Sprint(keyInterface)
 		}
 
-		if out[keyString], err = convertValueToStringKeys(in.MapIndex(key)); err != nil {
+		var val interface{}
+		if val, err = convertValueToStringKeys(in.MapIndex(key), policy); err == errSkipValue {
+			// Skipped entries are omitted
+			err = nil
+			continue
+		} else if err != nil {
 			out = nil
 			return
 		}
+		out[keyString] = val
 	}
 
 	return
 }
+
+// parseScalar converts the string s to a value of type t, like a JSON pointer token to a map key.
+// Only strings, numbers and booleans are converted, s is returned as-is for all other types or if the
+// conversion fails.
+func parseScalar(t reflect.Type, s string) interface{} {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return s
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return s
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return s
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return s
+		}
+		v.SetBool(b)
+	default:
+		return s
+	}
+	return v.Interface()
+}
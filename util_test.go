@@ -374,25 +374,41 @@ func TestForceStringMapKeys(t *testing.T) {
 	require.Nil(t, res)
 	require.EqualError(t, err, "Conversion of type structmapper_test.testStruct (kind: struct) is not supported")
 
-	// Test: chan value should give us an error
+	// Test: chan value should be skipped
 	ch := make(chan bool)
 	defer close(ch)
 	chanValueMap := map[string]interface{}{
 		"0": ch,
+		"1": "a",
 	}
 
 	res, err = structmapper.ForceStringMapKeys(chanValueMap)
-	require.Nil(t, res)
-	require.EqualError(t, err, "Conversion of type chan bool (kind: chan) is not supported")
+	require.NoError(t, err)
+	require.EqualValues(t, map[string]interface{}{
+		"1": "a",
+	}, res)
 
-	// Test: func value should give us an error
+	// Test: func value should be skipped
 	funcValueMap := map[string]interface{}{
 		"0": testFn,
+		"1": []interface{}{testFn, "a"},
 	}
 
 	res, err = structmapper.ForceStringMapKeys(funcValueMap)
-	require.Nil(t, res)
-	require.EqualError(t, err, "Conversion of type func() (kind: func) is not supported")
+	require.NoError(t, err)
+	require.EqualValues(t, map[string]interface{}{
+		"1": []interface{}{nil, "a"},
+	}, res)
+
+	// Test: nil slice and array elements should be retained
+	nilElementMap := map[string]interface{}{
+		"0": []interface{}{nil, "a"},
+		"1": [2]interface{}{"a", nil},
+	}
+
+	res, err = structmapper.ForceStringMapKeys(nilElementMap)
+	require.NoError(t, err)
+	require.EqualValues(t, nilElementMap, res)
 
 	// Test: pointer conversion
 	testString := "test"